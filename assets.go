@@ -0,0 +1,34 @@
+package nova
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+//go:embed web/*.css web/*.js web/images/*.svg
+var embeddedAssets embed.FS
+
+// StaticAssets are the stylesheets, scripts, images and static pages
+// the generated pages depend on.
+var StaticAssets fs.FS = mustSub(embeddedAssets, "web")
+
+// CopyStaticAssets writes StaticAssets to the site in dir,
+// replacing the files with the same name.
+func CopyStaticAssets(dir string) error {
+	return fs.WalkDir(StaticAssets, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(path))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := fs.ReadFile(StaticAssets, path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
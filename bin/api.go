@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// apiVersion is the version of the static JSON API, it's part of its path
+// so breaking changes can be published next to the previous version.
+const apiVersion = "v1"
+
+// apiDir is where the static API is written, relative to the site root.
+var apiDir = filepath.Join("api", apiVersion)
+
+// All the paths in the API documents are relative to the site root.
+
+// apiPlaylists is the content of api/v1/playlists.json.
+type apiPlaylists struct {
+	Version   string               `json:"version"`
+	Playlists []apiPlaylistSummary `json:"playlists"`
+}
+
+type apiPlaylistSummary struct {
+	Name string `json:"name"`
+	// Kind is monthly, yearly or all-time.
+	Kind       string       `json:"kind"`
+	Title      string       `json:"title"`
+	Year       int          `json:"year,omitempty"`
+	Month      int          `json:"month,omitempty"`
+	TrackCount int          `json:"trackCount"`
+	TopTrack   *apiTrackRef `json:"topTrack,omitempty"`
+	URL        string       `json:"url"`
+	HTMLURL    string       `json:"htmlUrl"`
+}
+
+type apiTrackRef struct {
+	ID         string `json:"id"`
+	Artist     string `json:"artist"`
+	ArtistSlug string `json:"artistSlug"`
+	Title      string `json:"title"`
+	Count      int    `json:"count"`
+	URL        string `json:"url"`
+}
+
+// apiTrack is the content of api/v1/tracks/<id>.json.
+type apiTrack struct {
+	Version         string                 `json:"version"`
+	ID              string                 `json:"id"`
+	Artist          string                 `json:"artist"`
+	ArtistSlug      string                 `json:"artistSlug"`
+	ArtistURL       string                 `json:"artistUrl"`
+	Title           string                 `json:"title"`
+	TotalCount      int                    `json:"totalCount"`
+	DurationSeconds int                    `json:"durationSeconds,omitempty"`
+	ThumbnailURL    string                 `json:"thumbnailUrl,omitempty"`
+	YTMusic         *nova.YTMusicJSON      `json:"youtubeMusic,omitempty"`
+	Spotify         *nova.SpotifyTrackJSON `json:"spotify,omitempty"`
+	// Charts are the monthly charts the track was in, chronologically.
+	Charts []apiChartAppearance `json:"charts"`
+}
+
+type apiChartAppearance struct {
+	Name    string `json:"name"`
+	Title   string `json:"title"`
+	Rank    int    `json:"rank"`
+	Count   int    `json:"count"`
+	URL     string `json:"url"`
+	HTMLURL string `json:"htmlUrl"`
+}
+
+// apiArtist is the content of api/v1/artists/<slug>.json.
+type apiArtist struct {
+	Version    string        `json:"version"`
+	Slug       string        `json:"slug"`
+	Name       string        `json:"name"`
+	TotalCount int           `json:"totalCount"`
+	Tracks     []apiTrackRef `json:"tracks"`
+}
+
+// writeAPI generates the documents of the static JSON API under web/api/v1
+// whose charts changed, from the charts the HTML pages are rendered from.
+// monthly must be sorted chronologically and catalog built from them.
+func writeAPI(build *siteBuild, monthly, yearly []*nova.Playlist, allTimes *nova.Playlist, catalog *nova.Catalog) error {
+	var playlists, tracks, artists int
+	index := apiPlaylists{Version: apiVersion}
+	var all []*nova.Playlist
+	// most recent first, like the index page
+	for i := len(monthly) - 1; i >= 0; i-- {
+		all = append(all, monthly[i])
+	}
+	for i := len(yearly) - 1; i >= 0; i-- {
+		all = append(all, yearly[i])
+	}
+	all = append(all, allTimes)
+
+	for _, playlist := range all {
+		summary := apiPlaylistSummary{
+			Name:       playlist.Name,
+			Kind:       playlistKind(playlist),
+			Title:      playlist.Title(),
+			Year:       playlist.Year,
+			Month:      playlist.Month,
+			TrackCount: len(playlist.Tracks),
+			URL:        apiPlaylistPath(playlist),
+			HTMLURL:    playlist.Basename() + ".html",
+		}
+		if len(playlist.Tracks) > 0 {
+			ref := newAPITrackRef(playlist.Tracks[0])
+			summary.TopTrack = &ref
+		}
+		index.Playlists = append(index.Playlists, summary)
+		if !build.stale(summary.URL, build.chartInputs(playlist, monthly)) {
+			continue
+		}
+		if err := writeAPIFile(summary.URL, playlist.JSON()); err != nil {
+			return err
+		}
+		playlists++
+	}
+	if build.stale(filepath.ToSlash(filepath.Join(apiDir, "playlists.json")), build.playlistsInputs("api", monthly)) {
+		if err := writeAPIFile(filepath.Join(apiDir, "playlists.json"), index); err != nil {
+			return err
+		}
+	}
+
+	for _, track := range catalog.Tracks {
+		if !build.stale(apiTrackPath(track.ID), build.trackInputs(track)) {
+			continue
+		}
+		doc := apiTrack{
+			Version:         apiVersion,
+			ID:              track.ID,
+			Artist:          track.Track.Artist,
+			ArtistSlug:      track.Track.ArtistSlug(),
+			ArtistURL:       apiArtistPath(track.Track.ArtistSlug()),
+			Title:           track.Track.Title,
+			TotalCount:      track.Track.Count,
+			DurationSeconds: track.Track.DurationSeconds(),
+			ThumbnailURL:    track.Track.ThumbURL(),
+		}
+		details := track.Track.JSON()
+		doc.YTMusic, doc.Spotify = details.YTMusic, details.Spotify
+		for _, appearance := range track.Appearances {
+			doc.Charts = append(doc.Charts, apiChartAppearance{
+				Name:    appearance.Playlist.Name,
+				Title:   appearance.Playlist.Title(),
+				Rank:    appearance.Rank,
+				Count:   appearance.Count,
+				URL:     apiPlaylistPath(appearance.Playlist),
+				HTMLURL: appearance.Playlist.Basename() + ".html",
+			})
+		}
+		if err := writeAPIFile(apiTrackPath(track.ID), doc); err != nil {
+			return err
+		}
+		tracks++
+	}
+
+	for _, artist := range catalog.Artists {
+		if !build.stale(apiArtistPath(artist.Slug), build.artistInputs(artist)) {
+			continue
+		}
+		doc := apiArtist{
+			Version:    apiVersion,
+			Slug:       artist.Slug,
+			Name:       artist.Name,
+			TotalCount: artist.TotalCount,
+		}
+		for _, track := range artist.Tracks {
+			doc.Tracks = append(doc.Tracks, newAPITrackRef(track.Track))
+		}
+		if err := writeAPIFile(apiArtistPath(artist.Slug), doc); err != nil {
+			return err
+		}
+		artists++
+	}
+
+	fmt.Printf("Generated the JSON API: %d playlists, %d tracks, %d artists\n", playlists, tracks, artists)
+	return nil
+}
+
+func newAPITrackRef(track *nova.Track) apiTrackRef {
+	return apiTrackRef{
+		ID:         track.ID(),
+		Artist:     track.Artist,
+		ArtistSlug: track.ArtistSlug(),
+		Title:      track.Title,
+		Count:      track.Count,
+		URL:        apiTrackPath(track.ID()),
+	}
+}
+
+// playlistKind tells what period a generated playlist covers.
+func playlistKind(playlist *nova.Playlist) string {
+	switch {
+	case playlist.Year == 0:
+		return "all-time"
+	case playlist.Month == 0:
+		return "yearly"
+	}
+	return "monthly"
+}
+
+func apiPlaylistPath(playlist *nova.Playlist) string {
+	return filepath.ToSlash(filepath.Join(apiDir, "playlists", playlist.Basename()+".json"))
+}
+
+func apiTrackPath(id string) string {
+	return filepath.ToSlash(filepath.Join(apiDir, "tracks", id+".json"))
+}
+
+func apiArtistPath(slug string) string {
+	return filepath.ToSlash(filepath.Join(apiDir, "artists", slug+".json"))
+}
+
+// writeAPIFile encodes v as JSON in the site at the given path.
+func writeAPIFile(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s - %w", path, err)
+	}
+	path = filepath.Join(*outFlag, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// written atomically like the pages, serve never hands out a partial document
+	return writeSiteFile(path, data)
+}
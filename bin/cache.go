@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattetti/nova-playlist"
+	"github.com/raitonoberu/ytmusic"
+)
+
+func cacheUsage() {
+	fmt.Fprintf(os.Stderr, `Usage of %s cache:
+  stats                               summary of the YT Music cache
+  show <query>                        details of a cached query
+  grep <pattern>                      list the entries matching a regular expression
+  evict [-n] <query|pattern>          remove a query, or all the entries matching a pattern
+  revalidate [-older-than 30d] [-n]   search the outdated entries again
+  export [-json]                      dump the cache to stdout
+`, os.Args[0])
+}
+
+// runCache implements the cache subcommands used to inspect and maintain
+// the YT Music cache stored at nova.YTMusicCachePath.
+func runCache(args []string) {
+	if len(args) == 0 {
+		cacheUsage()
+		os.Exit(2)
+	}
+
+	cache, err := nova.LoadYTMusicCache()
+	if err != nil {
+		log.Fatal(fmt.Errorf("Failed to load the YT music cache - %w", err))
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "stats":
+		cacheStats(cache)
+	case "show":
+		if len(args) != 1 {
+			cacheUsage()
+			os.Exit(2)
+		}
+		cacheShow(cache, args[0])
+	case "grep":
+		if len(args) != 1 {
+			cacheUsage()
+			os.Exit(2)
+		}
+		cacheGrep(cache, args[0])
+	case "evict":
+		cacheEvict(cache, args)
+	case "revalidate":
+		cacheRevalidate(cache, args)
+	case "export":
+		cacheExport(cache, args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown cache command %q\n", cmd)
+		cacheUsage()
+		os.Exit(2)
+	}
+}
+
+// cacheAgeBuckets are the age ranges used by the stats, from the most recent.
+var cacheAgeBuckets = []struct {
+	Label  string
+	MaxAge time.Duration
+}{
+	{"< 1 day", 24 * time.Hour},
+	{"< 1 week", 7 * 24 * time.Hour},
+	{"< 1 month", 30 * 24 * time.Hour},
+	{"< 3 months", 90 * 24 * time.Hour},
+	{"< 1 year", 365 * 24 * time.Hour},
+}
+
+func cacheStats(cache *nova.YTMusicCache) {
+	var tracks, artistsOnly, misses, unknownAge int
+	ages := make([]int, len(cacheAgeBuckets)+1)
+
+	queries := cache.Queries()
+	for _, query := range queries {
+		result, matchedAt, _ := cache.Lookup(query)
+		switch {
+		case result != nil && len(result.Tracks) > 0:
+			tracks++
+		case result != nil && len(result.Artists) > 0:
+			artistsOnly++
+		default:
+			misses++
+		}
+
+		if matchedAt.IsZero() {
+			unknownAge++
+			continue
+		}
+		age := time.Since(matchedAt)
+		bucket := len(cacheAgeBuckets)
+		for i, b := range cacheAgeBuckets {
+			if age < b.MaxAge {
+				bucket = i
+				break
+			}
+		}
+		ages[bucket]++
+	}
+
+	fmt.Println("Cache:", nova.YTMusicCachePath)
+	fmt.Println("Entries:", len(queries))
+	fmt.Println("  with a track match:", tracks)
+	fmt.Println("  with artists only:", artistsOnly)
+	fmt.Println("  misses (no results):", misses)
+	fmt.Println("Age:")
+	for i, b := range cacheAgeBuckets {
+		fmt.Printf("  %-10s %d\n", b.Label, ages[i])
+	}
+	fmt.Printf("  %-10s %d\n", ">= 1 year", ages[len(cacheAgeBuckets)])
+	fmt.Printf("  %-10s %d\n", "unknown", unknownAge)
+}
+
+func cacheShow(cache *nova.YTMusicCache, query string) {
+	result, matchedAt, ok := cache.Lookup(query)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%q isn't cached, try `cache grep` to find similar queries\n", query)
+		os.Exit(1)
+	}
+	fmt.Println("Query:", query)
+	if matchedAt.IsZero() {
+		fmt.Println("Matched at: unknown")
+	} else {
+		fmt.Println("Matched at:", matchedAt.Format(time.RFC3339))
+	}
+	if result == nil {
+		return
+	}
+	for i, track := range result.Tracks {
+		fmt.Printf("Track #%d: %s by %s\n", i+1, track.Title, ytArtistNames(track.Artists))
+		fmt.Println("  URL:", "https://music.youtube.com/watch?v="+track.VideoID)
+		if track.Album.Name != "" {
+			fmt.Println("  Album:", track.Album.Name)
+		}
+		fmt.Println("  Duration:", time.Duration(track.Duration)*time.Second)
+	}
+	for i, artist := range result.Artists {
+		fmt.Printf("Artist #%d: %s (%s)\n", i+1, artist.Artist, artist.BrowseID)
+	}
+}
+
+func cacheGrep(cache *nova.YTMusicCache, pattern string) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		log.Fatal(fmt.Errorf("invalid pattern - %w", err))
+	}
+	for _, query := range cache.Queries() {
+		result, _, _ := cache.Lookup(query)
+		summary := cacheEntrySummary(result)
+		if re.MatchString(query) || re.MatchString(summary) {
+			fmt.Printf("%s -> %s\n", query, summary)
+		}
+	}
+}
+
+func cacheEvict(cache *nova.YTMusicCache, args []string) {
+	fs := flag.NewFlagSet("cache evict", flag.ExitOnError)
+	dryRun := fs.Bool("n", false, "only list the entries that would be evicted")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		cacheUsage()
+		os.Exit(2)
+	}
+	target := fs.Arg(0)
+
+	// an exact query wins over a pattern
+	queries := []string{target}
+	if _, _, ok := cache.Lookup(target); !ok {
+		re, err := regexp.Compile("(?i)" + target)
+		if err != nil {
+			log.Fatal(fmt.Errorf("%q isn't cached and isn't a valid pattern - %w", target, err))
+		}
+		queries = queries[:0]
+		for _, query := range cache.Queries() {
+			if re.MatchString(query) {
+				queries = append(queries, query)
+			}
+		}
+	}
+
+	for _, query := range queries {
+		fmt.Println("evicting", query)
+		if !*dryRun {
+			cache.Evict(query)
+		}
+	}
+	fmt.Println(len(queries), "entries evicted")
+	if *dryRun || len(queries) == 0 {
+		return
+	}
+	if err := cache.Save(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func cacheRevalidate(cache *nova.YTMusicCache, args []string) {
+	fs := flag.NewFlagSet("cache revalidate", flag.ExitOnError)
+	olderThan := fs.String("older-than", "30d", "revalidate the entries older than this duration (e.g. 12h, 30d), entries without a timestamp are always revalidated")
+	dryRun := fs.Bool("n", false, "only list the entries that would be revalidated")
+	fs.Parse(args)
+
+	maxAge, err := parseDays(*olderThan)
+	if err != nil {
+		log.Fatal(fmt.Errorf("invalid -older-than value - %w", err))
+	}
+
+	var outdated []string
+	for _, query := range cache.Queries() {
+		_, matchedAt, _ := cache.Lookup(query)
+		if matchedAt.IsZero() || time.Since(matchedAt) > maxAge {
+			outdated = append(outdated, query)
+		}
+	}
+	fmt.Println(len(outdated), "entries to revalidate")
+	if *dryRun {
+		for _, query := range outdated {
+			fmt.Println(query)
+		}
+		return
+	}
+
+	var failed int
+	for i, query := range outdated {
+		if err := cache.Revalidate(query); err != nil {
+			failed++
+			fmt.Println("\nfailed to revalidate", query, "-", err)
+		}
+		fmt.Printf("\rRevalidated %d/%d", i+1, len(outdated))
+		// don't lose everything if we get interrupted, the backups
+		// are only rotated once for the whole run
+		if (i+1)%50 == 0 {
+			if err := cache.Checkpoint(); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	fmt.Println()
+	if failed > 0 {
+		fmt.Println(failed, "entries failed to revalidate and were kept as is")
+	}
+	if err := cache.Save(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// cacheExportEntry is the JSON representation of a cache entry.
+type cacheExportEntry struct {
+	Query     string                `json:"query"`
+	MatchedAt *time.Time            `json:"matchedAt,omitempty"`
+	Result    *ytmusic.SearchResult `json:"result"`
+}
+
+func cacheExport(cache *nova.YTMusicCache, args []string) {
+	fs := flag.NewFlagSet("cache export", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "export the full entries as JSON instead of one tab separated line per query")
+	fs.Parse(args)
+
+	queries := cache.Queries()
+	if !*asJSON {
+		for _, query := range queries {
+			result, _, _ := cache.Lookup(query)
+			fmt.Printf("%s\t%s\n", query, cacheEntrySummary(result))
+		}
+		return
+	}
+
+	entries := make([]cacheExportEntry, 0, len(queries))
+	for _, query := range queries {
+		result, matchedAt, _ := cache.Lookup(query)
+		entry := cacheExportEntry{Query: query, Result: result}
+		if !matchedAt.IsZero() {
+			entry.MatchedAt = &matchedAt
+		}
+		entries = append(entries, entry)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entries); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// cacheEntrySummary describes the top match of a cached search result.
+func cacheEntrySummary(result *ytmusic.SearchResult) string {
+	switch {
+	case result != nil && len(result.Tracks) > 0:
+		track := result.Tracks[0]
+		return fmt.Sprintf("%s by %s (%s)", track.Title, ytArtistNames(track.Artists), track.VideoID)
+	case result != nil && len(result.Artists) > 0:
+		artist := result.Artists[0]
+		return fmt.Sprintf("artist %s (%s)", artist.Artist, artist.BrowseID)
+	}
+	return "no results"
+}
+
+func ytArtistNames(artists []ytmusic.Artist) string {
+	names := make([]string, 0, len(artists))
+	for _, artist := range artists {
+		names = append(names, artist.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseDays is like time.ParseDuration but also accepts a number of days (e.g. 30d).
+func parseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
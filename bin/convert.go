@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// runConvert implements the convert command: it saves every playlist of the
+// data directory in another storage format and records it as the format of
+// the directory.
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	to := fs.String("to", "", "storage format to convert the playlists to: gob, json or json.gz")
+	dryRun := fs.Bool("dry-run", false, "print the conversions without doing them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s convert:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	format, err := nova.ParseStorageFormat(*to)
+	if err != nil {
+		fs.Usage()
+		log.Fatal(err)
+	}
+
+	store := &nova.FSStore{}
+	keys, err := store.List(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var converted, failed int
+	for _, key := range keys {
+		path, err := store.File(key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if current, _ := nova.StorageFormatOf(path); current == format {
+			continue
+		}
+		dest := store.PathFormat(key, format)
+		playlist, err := nova.LoadPlaylistFromFile(path)
+		if err == nil {
+			err = checkConversion(playlist, format)
+		}
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", path, err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("%s → %s\n", path, dest)
+		if !*dryRun {
+			if err := playlist.SaveToFile(dest); err != nil {
+				log.Fatal(err)
+			}
+			if err := os.Remove(path); err != nil {
+				log.Fatal(err)
+			}
+		}
+		converted++
+	}
+
+	fmt.Printf("%d playlists converted to %s, %d left in place\n", converted, format, failed)
+	if failed > 0 {
+		// the directory keeps its format until all the playlists are converted
+		os.Exit(1)
+	}
+	if !*dryRun {
+		if err := store.SetStorageFormat(format); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// checkConversion makes sure the playlist reads back the same in format.
+func checkConversion(p *nova.Playlist, format nova.StorageFormat) error {
+	var buf bytes.Buffer
+	if err := nova.EncodePlaylist(&buf, p, format); err != nil {
+		return err
+	}
+	decoded, err := nova.DecodePlaylist(&buf, format)
+	if err != nil {
+		return err
+	}
+	original := *p
+	// the links between the playlists are set when loading them, they're not stored
+	original.PreviousPlaylist, original.NextPlaylist = nil, nil
+	if !reflect.DeepEqual(&original, decoded) {
+		return fmt.Errorf("the playlist doesn't read back the same in %s, check it with nova doctor", format)
+	}
+	return nil
+}
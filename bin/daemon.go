@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// runDaemon implements the daemon command: it fetches the playlist of the
+// previous day every night, generates the site after each fetch and creates
+// the YouTube playlist of the previous month at the beginning of each month.
+func runDaemon(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	tz := fs.String("tz", "Europe/Paris", "time zone of the schedule, the days of the playlists start at midnight there")
+	fetchAt := fs.String("fetch-at", "04:00", "time of the day the playlist of the previous day is fetched, the site is generated after it")
+	youtubeAt := fs.String("youtube-at", "05:00", "time of the 1st of the month the YouTube playlist of the previous month is created")
+	youtubeCmd := fs.String("youtube-cmd", "", "command creating the YouTube playlist (e.g. ytplaylist), -month and -year are appended, the job is disabled when empty")
+	jitter := fs.Duration("jitter", 10*time.Minute, "maximum random delay added to the scheduled times")
+	lockPath := fs.String("lock", filepath.Join(nova.PlaylistDataPath, "daemon.lock"), "lock file preventing several daemons from running at the same time")
+	statusPath := fs.String("status", filepath.Join(nova.PlaylistDataPath, "daemon-status.json"), "file recording the last run of each job")
+	// the site is generated with the same options as the main command
+	flag.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s daemon:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		log.Fatal(fmt.Errorf("Invalid time zone %s - %w", *tz, err))
+	}
+	siteJob := &daemonJob{name: "site", run: func(time.Time) error {
+		return generateSite()
+	}}
+	fetchJob, err := newDaemonJob("fetch", *fetchAt, 0, fetchPreviousDay)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fetchJob.onSuccess = siteJob
+	jobs := []*daemonJob{fetchJob}
+	if *youtubeCmd != "" {
+		youtubeJob, err := newDaemonJob("youtube", *youtubeAt, 1, func(now time.Time) error {
+			return createYouTubePlaylist(*youtubeCmd, now.AddDate(0, -1, 0))
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		jobs = append(jobs, youtubeJob)
+	}
+
+	setupGeneration()
+	if _, err := nova.LoadYTMusicCache(); err != nil {
+		log.Fatal(fmt.Errorf("Failed to load the YT music cache - %w", err))
+	}
+	release, err := acquireLock(*lockPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer release()
+
+	d := &daemon{jobs: jobs, loc: loc, jitter: *jitter, statusPath: *statusPath}
+	d.loadStatus()
+	d.status.PID = os.Getpid()
+	d.status.StartedAt = time.Now()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// a second signal stops the daemon right away
+		stop()
+		fmt.Println("Stopping the daemon once the running job is done")
+	}()
+	d.run(ctx)
+}
+
+// daemonJob is a task of the daemon.
+type daemonJob struct {
+	name string
+	// hour and minute are the time of the day the job is scheduled at.
+	hour, minute int
+	// day is the day of the month the job is scheduled on, 0 for every day.
+	day int
+	// onSuccess is the job run right after this one when it succeeds.
+	onSuccess *daemonJob
+	// run runs the job scheduled at now.
+	run func(now time.Time) error
+}
+
+// newDaemonJob returns a job scheduled at the time of the day at, formatted as 15:04.
+func newDaemonJob(name, at string, day int, run func(now time.Time) error) (*daemonJob, error) {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %s for the %s job - %w", at, name, err)
+	}
+	return &daemonJob{name: name, hour: t.Hour(), minute: t.Minute(), day: day, run: run}, nil
+}
+
+// next returns the first time the job is scheduled at after t, in the location of t.
+func (j *daemonJob) next(t time.Time) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), j.hour, j.minute, 0, 0, t.Location())
+	for !next.After(t) || (j.day > 0 && next.Day() != j.day) {
+		next = time.Date(next.Year(), next.Month(), next.Day()+1, j.hour, j.minute, 0, 0, t.Location())
+	}
+	return next
+}
+
+// daemon runs its jobs one at a time, so they never overlap.
+type daemon struct {
+	jobs   []*daemonJob
+	loc    *time.Location
+	jitter time.Duration
+
+	statusPath string
+	status     daemonStatus
+}
+
+// daemonStatus is saved to the status file after each run.
+type daemonStatus struct {
+	PID       int                   `json:"pid"`
+	StartedAt time.Time             `json:"startedAt"`
+	Jobs      map[string]*jobStatus `json:"jobs"`
+}
+
+type jobStatus struct {
+	LastRun time.Time `json:"lastRun"`
+	// Duration is how long the last run took.
+	Duration    string    `json:"duration"`
+	Error       string    `json:"error,omitempty"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	NextRun     time.Time `json:"nextRun"`
+}
+
+func (d *daemon) run(ctx context.Context) {
+	for {
+		now := time.Now().In(d.loc)
+		var job *daemonJob
+		var at time.Time
+		for _, j := range d.jobs {
+			next := j.next(now)
+			if d.jitter > 0 {
+				next = next.Add(time.Duration(rand.Int63n(int64(d.jitter))))
+			}
+			d.jobStatus(j.name).NextRun = next
+			if job == nil || next.Before(at) {
+				job, at = j, next
+			}
+		}
+		d.saveStatus()
+
+		fmt.Printf("Next job: %s at %s\n", job.name, at.Format(time.RFC1123))
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		d.runJob(job, at)
+	}
+}
+
+// runJob runs the job and records its outcome in the status file.
+func (d *daemon) runJob(job *daemonJob, now time.Time) {
+	fmt.Println("Running the", job.name, "job")
+	start := time.Now()
+	err := job.run(now)
+
+	status := d.jobStatus(job.name)
+	status.LastRun = start
+	status.Duration = time.Since(start).Round(time.Second).String()
+	status.Error = ""
+	if err != nil {
+		log.Printf("The %s job failed: %v", job.name, err)
+		status.Error = err.Error()
+	} else {
+		status.LastSuccess = start
+	}
+	d.saveStatus()
+
+	if err == nil && job.onSuccess != nil {
+		d.runJob(job.onSuccess, now)
+	}
+}
+
+func (d *daemon) jobStatus(name string) *jobStatus {
+	if d.status.Jobs == nil {
+		d.status.Jobs = make(map[string]*jobStatus)
+	}
+	if d.status.Jobs[name] == nil {
+		d.status.Jobs[name] = &jobStatus{}
+	}
+	return d.status.Jobs[name]
+}
+
+// loadStatus loads the status left by the previous daemon, if any.
+func (d *daemon) loadStatus() {
+	data, err := os.ReadFile(d.statusPath)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &d.status); err != nil {
+		log.Println("Ignoring the invalid status file:", err)
+	}
+}
+
+func (d *daemon) saveStatus() {
+	err := nova.WriteFileAtomic(d.statusPath, 0644, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d.status)
+	})
+	if err != nil {
+		log.Println("Error saving the status file:", err)
+	}
+}
+
+// fetchPreviousDay fetches the playlist of the day before now and updates the playlist of its month.
+func fetchPreviousDay(now time.Time) error {
+	day := now.AddDate(0, 0, -1)
+	start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, time.UTC)
+	if _, err := fetchMonthlyPlaylist(start, end); err != nil {
+		return err
+	}
+	return nova.YTMusic.Save()
+}
+
+// createYouTubePlaylist runs the command creating the YouTube playlist of the month of date.
+func createYouTubePlaylist(command string, date time.Time) error {
+	args := strings.Fields(command)
+	args = append(args, "-month", strconv.Itoa(int(date.Month())), "-year", strconv.Itoa(date.Year()))
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %s - %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// acquireLock creates the lock file with the pid of the process. It fails if
+// the lock is held by another process still running, the lock files left by
+// processes which didn't exit cleanly are replaced.
+func acquireLock(path string) (release func(), err error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create the lock file %s - %w", path, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the lock file %s - %w", path, err)
+		}
+		if pid, _ := strconv.Atoi(strings.TrimSpace(string(data))); pid > 0 && processRunning(pid) {
+			return nil, fmt.Errorf("another daemon (pid %d) is running, see %s", pid, path)
+		}
+		fmt.Println("Removing the stale lock file", path)
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("failed to acquire the lock %s", path)
+}
+
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
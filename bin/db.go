@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mattetti/nova-playlist"
+	_ "modernc.org/sqlite"
+)
+
+// defaultDBPath is where nova db keeps the database when -db isn't passed.
+var defaultDBPath = filepath.Join(nova.PlaylistDataPath, "playlists.db")
+
+func dbUsage() {
+	fmt.Fprintf(os.Stderr, `Usage of %s db:
+  import [-db file] [-from dir]   import the playlists of a data directory in the database
+  sql [-db file] [-csv] <query>   run a SQL query on the database and print the rows
+`, os.Args[0])
+}
+
+// runDB implements the db subcommands used to fill and query the SQLite
+// database of the playlists.
+func runDB(args []string) {
+	if len(args) == 0 {
+		dbUsage()
+		os.Exit(2)
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "import":
+		dbImport(args)
+	case "sql":
+		dbSQL(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown db command %q\n", cmd)
+		dbUsage()
+		os.Exit(2)
+	}
+}
+
+// useDatabase makes the program store the playlists in the -db database.
+func useDatabase() {
+	if *dbFlag == "" {
+		return
+	}
+	store, err := nova.OpenSQLStore(*dbFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	nova.Playlists = store
+}
+
+func dbImport(args []string) {
+	fs := flag.NewFlagSet("db import", flag.ExitOnError)
+	dbPath := fs.String("db", defaultDBPath, "SQLite database, created if needed")
+	from := fs.String("from", nova.PlaylistDataPath, "data directory of the playlists to import")
+	fs.Parse(args)
+
+	src := &nova.FSStore{Dir: *from}
+	keys, err := src.List(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := nova.OpenSQLStore(*dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	for i, key := range keys {
+		playlist, err := src.Load(key)
+		if err != nil {
+			log.Fatal(fmt.Errorf("failed to load the playlist %s - %w", key, err))
+		}
+		if err := db.Save(playlist); err != nil {
+			log.Fatal(err)
+		}
+		if (i+1)%100 == 0 {
+			fmt.Printf("%d/%d playlists imported\n", i+1, len(keys))
+		}
+	}
+	fmt.Printf("%d playlists imported in %s\n", len(keys), *dbPath)
+}
+
+func dbSQL(args []string) {
+	fs := flag.NewFlagSet("db sql", flag.ExitOnError)
+	dbPath := fs.String("db", defaultDBPath, "SQLite database")
+	asCSV := fs.Bool("csv", false, "print the rows as CSV instead of a table")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		dbUsage()
+		os.Exit(2)
+	}
+	if _, err := os.Stat(*dbPath); err != nil {
+		log.Fatal(fmt.Errorf("no database, create it with nova db import - %w", err))
+	}
+
+	db, err := nova.OpenSQLStore(*dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.DB().Query(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+	if err := printRows(rows, *asCSV); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printRows(rows *sql.Rows, asCSV bool) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var write func([]string) error
+	var flush func() error
+	if asCSV {
+		w := csv.NewWriter(os.Stdout)
+		write = w.Write
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		write = func(record []string) error {
+			_, err := fmt.Fprintln(w, strings.Join(record, "\t"))
+			return err
+		}
+		flush = w.Flush
+	}
+
+	if err := write(columns); err != nil {
+		return err
+	}
+	values := make([]any, len(columns))
+	for i := range values {
+		values[i] = new(sql.NullString)
+	}
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return err
+		}
+		record := make([]string, len(values))
+		for i, v := range values {
+			record[i] = v.(*sql.NullString).String
+		}
+		if err := write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
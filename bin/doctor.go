@@ -0,0 +1,323 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// doctorIssue is a problem found in a playlist file.
+type doctorIssue struct {
+	problem string
+	// fix repairs the playlist, nil when the issue can't be fixed safely.
+	fix func(p *nova.Playlist)
+}
+
+// runDoctor implements the doctor command: it checks every playlist of the
+// data directory and, with -fix, repairs the issues which can be fixed safely.
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "repair the issues which can be fixed safely, the files which can't be decoded are renamed to .corrupt")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s doctor:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	paths, err := playlistFiles(nova.PlaylistDataPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var checked, found, fixable, fixed int
+	for _, path := range paths {
+		checked++
+		playlist, err := nova.LoadPlaylistFromFile(path)
+		if err != nil {
+			found++
+			fixable++
+			fmt.Printf("%s\n  ~ can't be decoded: %v\n", path, err)
+			if *fix {
+				if err := os.Rename(path, path+".corrupt"); err != nil {
+					log.Fatal(err)
+				}
+				fmt.Println("  → renamed to", filepath.Base(path)+".corrupt")
+				fixed++
+			}
+			continue
+		}
+
+		issues := checkPlaylist(path, playlist)
+		if len(issues) == 0 {
+			continue
+		}
+		fmt.Println(path)
+		fixes := 0
+		for _, issue := range issues {
+			found++
+			mark := "✗"
+			if issue.fix != nil {
+				mark = "~"
+				fixes++
+			}
+			fmt.Printf("  %s %s\n", mark, issue.problem)
+			if *fix && issue.fix != nil {
+				issue.fix(playlist)
+			}
+		}
+		fixable += fixes
+		if *fix && fixes > 0 {
+			if err := playlist.SaveToFile(path); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("  → %d issues fixed\n", fixes)
+			fixed += fixes
+		}
+	}
+
+	fmt.Printf("%d playlists checked, %d issues found", checked, found)
+	if *fix {
+		fmt.Printf(", %d fixed", fixed)
+	}
+	fmt.Println()
+	if !*fix && fixable > 0 {
+		fmt.Println("The issues marked with ~ can be fixed with -fix")
+	}
+	if found > fixed {
+		os.Exit(1)
+	}
+}
+
+// checkPlaylist checks a playlist according to the kind of file it was loaded from.
+func checkPlaylist(path string, p *nova.Playlist) []*doctorIssue {
+	if nova.IsLegacyFilename(filepath.Base(path)) {
+		return []*doctorIssue{{problem: "the file is in the legacy layout, move it with nova migrate"}}
+	}
+	key, ok := (&nova.FSStore{}).KeyOf(path)
+	switch {
+	case !ok:
+		return []*doctorIssue{{problem: "the file isn't where a daily, monthly or yearly playlist is stored"}}
+	case key.Daily():
+		return checkDailyPlaylist(p, key)
+	case key.Monthly():
+		return checkMonthlyPlaylist(p, key)
+	}
+	return checkNilTracks(p)
+}
+
+func checkDailyPlaylist(p *nova.Playlist, key nova.PlaylistKey) []*doctorIssue {
+	var issues []*doctorIssue
+	if p.Name != "" || p.StoreKey() != key {
+		issues = append(issues, &doctorIssue{
+			problem: fmt.Sprintf("name %q and date %d-%02d-%02d don't match the filename", p.Name, p.Year, p.Month, p.Day),
+			fix: func(p *nova.Playlist) {
+				p.Name, p.Year, p.Month, p.Day = "", key.Year, key.Month, key.Day
+			},
+		})
+	}
+	issues = append(issues, checkNilTracks(p)...)
+	if len(p.Tracks) == 0 {
+		issues = append(issues, &doctorIssue{problem: "no plays, fetch the day again with nova backfill"})
+	}
+
+	invalidTimes := 0
+	seen := make(map[string]bool)
+	duplicates := 0
+	for _, track := range p.Tracks {
+		if track == nil {
+			continue
+		}
+		if track.Hour < 0 || track.Hour > 23 || track.Minute < 0 || track.Minute > 59 {
+			invalidTimes++
+		}
+		key := fmt.Sprintf("%02d:%02d|%s", track.Hour, track.Minute, track.Key())
+		if seen[key] {
+			duplicates++
+		}
+		seen[key] = true
+	}
+	if invalidTimes > 0 {
+		issues = append(issues, &doctorIssue{problem: fmt.Sprintf("%d plays with an invalid time", invalidTimes)})
+	}
+	if duplicates > 0 {
+		issues = append(issues, &doctorIssue{
+			problem: fmt.Sprintf("%d plays recorded twice", duplicates),
+			fix:     removeDuplicatePlays,
+		})
+	}
+	return issues
+}
+
+func checkMonthlyPlaylist(p *nova.Playlist, key nova.PlaylistKey) []*doctorIssue {
+	var issues []*doctorIssue
+	year, month := key.Year, key.Month
+	name := nova.MonthEnglishName(time.Month(month)) + "-" + strconv.Itoa(year)
+	if p.Name != name || p.Year != year || p.Month != month || p.Day != 0 {
+		issues = append(issues, &doctorIssue{
+			problem: fmt.Sprintf("name %q and date %d-%02d don't match the filename", p.Name, p.Year, p.Month),
+			fix: func(p *nova.Playlist) {
+				p.Name, p.Year, p.Month, p.Day = name, year, month, 0
+			},
+		})
+	}
+	issues = append(issues, checkNilTracks(p)...)
+
+	keys := make(map[string]bool)
+	duplicates, invalidCounts, plays := 0, 0, 0
+	sorted := true
+	for i, track := range p.Tracks {
+		if track == nil {
+			continue
+		}
+		if keys[track.Key()] {
+			duplicates++
+		}
+		keys[track.Key()] = true
+		if track.Count < 1 {
+			invalidCounts++
+		}
+		plays += track.Count
+		if i > 0 && p.Tracks[i-1] != nil && p.Tracks[i-1].Count < track.Count {
+			sorted = false
+		}
+	}
+	if duplicates > 0 {
+		issues = append(issues, &doctorIssue{
+			problem: fmt.Sprintf("%d tracks listed twice", duplicates),
+			fix:     mergeDuplicateTracks,
+		})
+	}
+	if invalidCounts > 0 {
+		issues = append(issues, &doctorIssue{problem: fmt.Sprintf("%d tracks without plays", invalidCounts)})
+	}
+	if !sorted {
+		issues = append(issues, &doctorIssue{problem: "the tracks aren't sorted by plays", fix: (*nova.Playlist).Sort})
+	}
+
+	days := loadDailyPlaylists(year, month)
+	dailyPlays := 0
+	for _, day := range days {
+		dailyPlays += len(day.Tracks)
+	}
+	switch {
+	case len(p.Tracks) == 0 && dailyPlays == 0:
+		issues = append(issues, &doctorIssue{problem: "no tracks and no daily playlists to rebuild it from"})
+	case dailyPlays > plays:
+		// the daily playlists have plays the month is missing, it's safe to aggregate them again
+		issues = append(issues, &doctorIssue{
+			problem: fmt.Sprintf("%d plays but its %d daily playlists have %d", plays, len(days), dailyPlays),
+			fix: func(p *nova.Playlist) {
+				aggregateDailyPlaylists(p, days)
+			},
+		})
+	case dailyPlays > 0 && dailyPlays < plays:
+		issues = append(issues, &doctorIssue{
+			problem: fmt.Sprintf("%d plays but its %d daily playlists only have %d, see nova gaps", plays, len(days), dailyPlays),
+		})
+	}
+	return issues
+}
+
+func checkNilTracks(p *nova.Playlist) []*doctorIssue {
+	nils := 0
+	for _, track := range p.Tracks {
+		if track == nil {
+			nils++
+		}
+	}
+	if nils == 0 {
+		return nil
+	}
+	return []*doctorIssue{{
+		problem: fmt.Sprintf("%d empty tracks", nils),
+		fix: func(p *nova.Playlist) {
+			tracks := p.Tracks[:0]
+			for _, track := range p.Tracks {
+				if track != nil {
+					tracks = append(tracks, track)
+				}
+			}
+			p.Tracks = tracks
+		},
+	}}
+}
+
+func removeDuplicatePlays(p *nova.Playlist) {
+	seen := make(map[string]bool)
+	tracks := p.Tracks[:0]
+	for _, track := range p.Tracks {
+		key := fmt.Sprintf("%02d:%02d|%s", track.Hour, track.Minute, track.Key())
+		if !seen[key] {
+			tracks = append(tracks, track)
+		}
+		seen[key] = true
+	}
+	p.Tracks = tracks
+}
+
+// mergeDuplicateTracks sums the plays of the tracks listed more than once in the first of them.
+func mergeDuplicateTracks(p *nova.Playlist) {
+	byKey := make(map[string]*nova.Track)
+	tracks := p.Tracks[:0]
+	for _, track := range p.Tracks {
+		if first, ok := byKey[track.Key()]; ok {
+			first.Count += track.Count
+			continue
+		}
+		byKey[track.Key()] = track
+		tracks = append(tracks, track)
+	}
+	p.Tracks = tracks
+	p.Sort()
+}
+
+// loadDailyPlaylists loads the daily playlists of a month available in the data directory.
+func loadDailyPlaylists(year, month int) []*nova.Playlist {
+	var days []*nova.Playlist
+	for day := 1; day <= 31; day++ {
+		date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		if int(date.Month()) != month {
+			break
+		}
+		playlist := &nova.Playlist{Year: year, Month: month, Day: day}
+		if err := playlist.LoadFromDisk(); err != nil {
+			continue
+		}
+		days = append(days, playlist)
+	}
+	return days
+}
+
+// aggregateDailyPlaylists replaces the tracks of the monthly playlist with the
+// plays of its days, keeping the YT Music matches it had.
+func aggregateDailyPlaylists(p *nova.Playlist, days []*nova.Playlist) {
+	previous := make(map[string]*nova.Track, len(p.Tracks))
+	for _, track := range p.Tracks {
+		if track != nil {
+			previous[track.Key()] = track
+		}
+	}
+	p.Tracks = nil
+	for _, day := range days {
+		for _, play := range day.Tracks {
+			track := *play
+			p.AddTracks([]*nova.Track{&track})
+		}
+	}
+	for _, track := range p.Tracks {
+		if old := previous[track.Key()]; old != nil {
+			if track.YTMusicInfo == nil {
+				track.YTMusicInfo = old.YTMusicInfo
+			}
+			if track.YTArtistID == "" {
+				track.YTArtistID = old.YTArtistID
+			}
+		}
+	}
+	p.Sort()
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+const (
+	feedTopTracks = 10
+	// feedMaxNewTracks caps the number of entries in the new tracks feed.
+	feedMaxNewTracks = 100
+)
+
+// atomFeed is an Atom feed document, see RFC 4287.
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Base      string      `xml:"xml:base,attr,omitempty"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	Updated   string      `xml:"updated"`
+	Author    atomAuthor  `xml:"author"`
+	Links     []atomLink  `xml:"link"`
+	Entries   []atomEntry `xml:"entry"`
+	Generator string      `xml:"generator,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// writeFeeds generates the Atom feeds of the site: web/feed.xml with an entry
+// per chart and web/new-tracks.xml with the tracks played for the first time.
+// playlists must be sorted chronologically.
+func writeFeeds(build *siteBuild, playlists []*nova.Playlist) error {
+	feeds := map[string]func([]*nova.Playlist) *atomFeed{
+		"feed.xml":       chartsFeed,
+		"new-tracks.xml": newTracksFeed,
+	}
+	// the entry of the current period is dated with the day of the build
+	inputs := append(build.playlistsInputs("feed", playlists), time.Now().UTC().Format(time.DateOnly))
+	for filename, newFeed := range feeds {
+		if !build.stale(filename, inputs) {
+			continue
+		}
+		feed := newFeed(playlists)
+		feed.setUpdated()
+		data, err := feed.Marshal()
+		if err != nil {
+			return fmt.Errorf("failed to generate %s - %w", filename, err)
+		}
+		path := filepath.Join(*outFlag, filename)
+		if err := writeSiteFile(path, data); err != nil {
+			return err
+		}
+		fmt.Println("Generated feed", path)
+	}
+	return nil
+}
+
+// setUpdated dates the feed with its most recent entry, so it only changes
+// when its entries do.
+func (f *atomFeed) setUpdated() {
+	f.Updated = time.Unix(0, 0).UTC().Format(time.RFC3339)
+	for _, entry := range f.Entries {
+		// the dates are all UTC in RFC 3339, they sort as strings
+		if entry.Updated > f.Updated {
+			f.Updated = entry.Updated
+		}
+	}
+}
+
+func (f *atomFeed) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+func newAtomFeed(filename, title, subtitle string) *atomFeed {
+	return &atomFeed{
+		Base:     *baseURLFlag,
+		ID:       feedID(filename),
+		Title:    title,
+		Subtitle: subtitle,
+		Author:   atomAuthor{Name: "Radio Nova"},
+		Links: []atomLink{
+			{Href: filename, Rel: "self", Type: "application/atom+xml"},
+			{Href: "index.html", Rel: "alternate", Type: "text/html"},
+		},
+		Generator: "nova-playlist",
+	}
+}
+
+// chartsFeed has an entry per chart, most recent first, listing its top tracks.
+func chartsFeed(playlists []*nova.Playlist) *atomFeed {
+	feed := newAtomFeed("feed.xml", "Radio Nova charts", "The most played tracks on Radio Nova")
+	for i := len(playlists) - 1; i >= 0; i-- {
+		playlist := playlists[i]
+		var content strings.Builder
+		content.WriteString("<ol>")
+		for j, track := range playlist.Tracks {
+			if j >= feedTopTracks {
+				break
+			}
+			fmt.Fprintf(&content, "<li>%s by %s (%d plays)</li>", html.EscapeString(track.Title), html.EscapeString(track.Artist), track.Count)
+		}
+		content.WriteString("</ol>")
+
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      feedID("charts/" + url.PathEscape(playlist.Name)),
+			Title:   "Radio Nova " + playlist.Title(),
+			Updated: playlistUpdated(playlist).Format(time.RFC3339),
+			Links:   []atomLink{{Href: url.PathEscape(playlist.Name) + ".html", Rel: "alternate", Type: "text/html"}},
+			Content: atomContent{Type: "html", Body: content.String()},
+		})
+	}
+	return feed
+}
+
+// newTracksFeed has an entry per track played for the first time, most recent first.
+// The tracks of the oldest playlist aren't included since there's nothing to compare them to.
+func newTracksFeed(playlists []*nova.Playlist) *atomFeed {
+	feed := newAtomFeed("new-tracks.xml", "Radio Nova new tracks", "Tracks played on Radio Nova for the first time")
+	for _, debut := range trackDebuts(playlists) {
+		if len(feed.Entries) >= feedMaxNewTracks {
+			break
+		}
+		track, playlist := debut.Track, debut.Playlist
+		if playlist == playlists[0] {
+			break
+		}
+		content := fmt.Sprintf("<p>%s by %s was played %d times in %s.</p>",
+			html.EscapeString(track.Title), html.EscapeString(track.Artist), track.Count, html.EscapeString(playlist.Title()))
+		if u := track.PlaybackURL(); u != "" {
+			content += fmt.Sprintf(`<p><a href="%s">Listen</a></p>`, html.EscapeString(u))
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      feedID("tracks/" + url.PathEscape(playlist.Name) + "/" + url.PathEscape(track.Key())),
+			Title:   fmt.Sprintf("%s by %s", track.Title, track.Artist),
+			Updated: playlistUpdated(playlist).Format(time.RFC3339),
+			Links:   []atomLink{{Href: url.PathEscape(playlist.Name) + ".html", Rel: "alternate", Type: "text/html"}},
+			Content: atomContent{Type: "html", Body: content},
+		})
+	}
+	return feed
+}
+
+// trackDebut is the first playlist a track appeared in.
+type trackDebut struct {
+	Track    *nova.Track
+	Playlist *nova.Playlist
+}
+
+// trackDebuts returns the first appearance of each track, most recent first
+// and by play count within the same playlist. playlists must be sorted chronologically.
+func trackDebuts(playlists []*nova.Playlist) []trackDebut {
+	seen := make(map[string]bool)
+	var debuts []trackDebut
+	for _, playlist := range playlists {
+		var monthDebuts []trackDebut
+		for _, track := range playlist.Tracks {
+			if seen[track.Key()] {
+				continue
+			}
+			seen[track.Key()] = true
+			monthDebuts = append(monthDebuts, trackDebut{Track: track, Playlist: playlist})
+		}
+		sort.SliceStable(monthDebuts, func(i, j int) bool {
+			return monthDebuts[i].Track.Count > monthDebuts[j].Track.Count
+		})
+		debuts = append(monthDebuts, debuts...)
+	}
+	return debuts
+}
+
+// playlistUpdated is the date a playlist was last updated: the end of its period,
+// or the start of the day for the current period, so the builds of a day agree.
+func playlistUpdated(playlist *nova.Playlist) time.Time {
+	var end time.Time
+	switch {
+	case playlist.Day > 0:
+		end = time.Date(playlist.Year, time.Month(playlist.Month), playlist.Day+1, 0, 0, 0, 0, time.UTC)
+	case playlist.Month > 0:
+		end = time.Date(playlist.Year, time.Month(playlist.Month)+1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		end = time.Date(playlist.Year+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	end = end.Add(-time.Second)
+	if now := time.Now().UTC(); end.After(now) {
+		return now.Truncate(24 * time.Hour)
+	}
+	return end
+}
+
+// feedID returns a permanent identifier for a feed or an entry: its URL when
+// the site URL is known, a tag URI otherwise.
+func feedID(path string) string {
+	if *baseURLFlag != "" {
+		return strings.TrimSuffix(*baseURLFlag, "/") + "/" + path
+	}
+	return "tag:nova-playlist,2022:" + path
+}
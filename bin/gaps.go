@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// dayRange are the flags selecting the days checked by the gaps and backfill commands.
+type dayRange struct {
+	from, to *string
+	short    *float64
+}
+
+func newDayRange(fs *flag.FlagSet) *dayRange {
+	return &dayRange{
+		from:  fs.String("from", "", "first day to check, as 2006-01-02 (90 days before -to by default)"),
+		to:    fs.String("to", "", "last day to check, as 2006-01-02 (yesterday by default)"),
+		short: fs.Float64("short", 0.5, "days with fewer plays than this ratio of the median day are reported as short"),
+	}
+}
+
+// scan reports on the daily playlists of the range.
+func (r *dayRange) scan() ([]*nova.DayReport, int, error) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now().In(paris)
+	end := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+	if *r.to != "" {
+		if end, err = time.Parse("2006-01-02", *r.to); err != nil {
+			return nil, 0, fmt.Errorf("invalid -to day - %w", err)
+		}
+	}
+	start := end.AddDate(0, 0, -89)
+	if *r.from != "" {
+		if start, err = time.Parse("2006-01-02", *r.from); err != nil {
+			return nil, 0, fmt.Errorf("invalid -from day - %w", err)
+		}
+	}
+	if start.After(end) {
+		return nil, 0, fmt.Errorf("-from %s is after -to %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+	fmt.Println("Checking the days from", start.Format("2006-01-02"), "to", end.Format("2006-01-02"))
+	return nova.ScanDays(start, end, *r.short)
+}
+
+// runGaps implements the gaps command: it reports the missing and short days.
+func runGaps(args []string) {
+	fs := flag.NewFlagSet("gaps", flag.ExitOnError)
+	days := newDayRange(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s gaps:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	reports, median, err := days.scan()
+	if err != nil {
+		log.Fatal(err)
+	}
+	gaps := nova.Gaps(reports)
+	for _, gap := range gaps {
+		printGap(gap, median)
+	}
+	fmt.Printf("%d of %d days missing or short (median: %d plays a day)\n", len(gaps), len(reports), median)
+	if len(gaps) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printGap(gap *nova.DayReport, median int) {
+	day := gap.Date.Format("2006-01-02 Mon")
+	if gap.Missing() {
+		fmt.Printf("%s  missing                %d cached pages\n", day, gap.CachedPages)
+		return
+	}
+	fmt.Printf("%s  short    %4d plays %3.0f%%  %d cached pages\n", day, gap.Plays, float64(gap.Plays)/float64(median)*100, gap.CachedPages)
+}
+
+// runBackfill implements the backfill command: it fetches the missing and
+// short days again and updates the playlists of their months.
+func runBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	days := newDayRange(fs)
+	delay := fs.Duration("delay", 5*time.Second, "pause between two days, on top of the throttling of the page requests")
+	monthly := fs.Bool("monthly", true, "update the playlists of the months of the fetched days")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s backfill:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	reports, median, err := days.scan()
+	if err != nil {
+		log.Fatal(err)
+	}
+	gaps := nova.Gaps(reports)
+	if len(gaps) == 0 {
+		fmt.Println("No missing or short days")
+		return
+	}
+	nonce, err := nova.GetNonce()
+	if err != nil {
+		log.Fatal(fmt.Errorf("Failed to get the nonce - %w", err))
+	}
+
+	months := make(map[time.Time]bool)
+	failed := 0
+	for i, gap := range gaps {
+		if i > 0 {
+			time.Sleep(*delay)
+		}
+		printGap(gap, median)
+		var previous *nova.Playlist
+		if gap.Short {
+			previous = &nova.Playlist{Year: gap.Date.Year(), Month: int(gap.Date.Month()), Day: gap.Date.Day()}
+			if err := previous.LoadFromDisk(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		// the cached pages of a short day are what it was made of, they are fetched again too
+		if err := nova.RemoveDailyPlaylist(gap.Date, gap.Short); err != nil {
+			log.Fatal(err)
+		}
+		playlist := nova.GetPlaylist(gap.Date, nonce)
+		if playlist == nil {
+			failed++
+			if previous != nil {
+				if err := previous.SaveToDisk(); err != nil {
+					log.Fatal(err)
+				}
+			}
+			continue
+		}
+		fmt.Printf("%s  fetched  %4d plays\n", gap.Date.Format("2006-01-02 Mon"), len(playlist.Tracks))
+		months[time.Date(gap.Date.Year(), gap.Date.Month(), 1, 0, 0, 0, 0, time.UTC)] = true
+	}
+
+	if *monthly && len(months) > 0 {
+		if err := updateMonthlyPlaylists(months); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if failed > 0 {
+		log.Fatalf("%d of %d days couldn't be fetched", failed, len(gaps))
+	}
+}
+
+// updateMonthlyPlaylists aggregates the daily playlists of the months again,
+// up to yesterday for the current month.
+func updateMonthlyPlaylists(months map[time.Time]bool) error {
+	if _, err := nova.LoadYTMusicCache(); err != nil {
+		return fmt.Errorf("failed to load the YT music cache - %w", err)
+	}
+	defer nova.YTMusic.Save()
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		return err
+	}
+	now := time.Now().In(paris)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var starts []time.Time
+	for start := range months {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for _, start := range starts {
+		end := start.AddDate(0, 1, 0)
+		if end.After(today) {
+			end = today
+		}
+		if _, err := fetchMonthlyPlaylist(start, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// runLibraries downloads the pinned third-party libraries and prints their
+// integrity in the libraries.sum format.
+func runLibraries(args []string) {
+	fmt.Println("# Subresource Integrity of the libraries in nova.Libraries, one \"<url> <integrity>\" per line.")
+	fmt.Println("# Regenerate it with `nova libraries > libraries.sum` after changing a library and rebuild.")
+	for _, lib := range nova.Libraries {
+		// verify the new version, not the pinned one
+		lib.Integrity = ""
+		data, err := lib.Download()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(lib.URL, nova.Integrity(data))
+	}
+}
@@ -1,10 +1,9 @@
 package main
 
 import (
-	"bytes"
+	"errors"
 	"flag"
 	"fmt"
-	"html/template"
 	"log"
 	"os"
 	"path/filepath"
@@ -21,17 +20,75 @@ var monthFlag = flag.Int("month", 0, "the month to process (e.g. 1 for January,
 var yearFlag = flag.Int("year", 0, "the year to process (current if not set)")
 var fetchFlag = flag.Bool("fetch", false, "fetch the playlist from the Radio Nova website")
 var genFlag = flag.Bool("gen", true, "generate the HTML page for the playlist")
+var baseURLFlag = flag.String("base-url", "", "public URL of the generated site, used for the feed links and IDs (e.g. https://example.com/nova/)")
+var outFlag = flag.String("out", "web", "directory the site is generated in, the static assets are copied there unless it's the web directory they come from")
+var vendorFlag = flag.Bool("vendor", false, "copy the pinned third-party libraries to the site instead of loading them from their CDN, so it works offline")
+var fullFlag = flag.Bool("full", false, "generate the whole site, even the pages whose inputs didn't change since the last build")
+var dbFlag = flag.String("db", "", "SQLite database storing the playlists instead of the data directory, see nova db")
+var templatesFlag = flag.String("templates", "", "directory of HTML templates replacing the default ones (playlist.html, index.html, track.html, artist.html, search.html)")
 
 func usage() {
 	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, `
+Commands:
+  backfill   fetch the missing and short days again
+  cache      inspect and maintain the YT Music cache
+  convert    save the playlists of the data directory in another storage format
+  db         import the playlists in a SQLite database and query it
+  daemon     fetch the playlists, generate the site and create the YouTube playlists on a schedule
+  doctor     check the playlists of the data directory and repair them
+  gaps       report the missing and short days in the data directory
+  libraries  print the integrity of the pinned third-party libraries for libraries.sum
+  migrate    move the playlists to the current layout of the data directory
+  query      filter and group the plays of the daily playlists
+  serve      serve the site and regenerate it when the data changes
+`)
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backfill":
+			runBackfill(os.Args[2:])
+			return
+		case "cache":
+			runCache(os.Args[2:])
+			return
+		case "convert":
+			runConvert(os.Args[2:])
+			return
+		case "db":
+			runDB(os.Args[2:])
+			return
+		case "daemon":
+			runDaemon(os.Args[2:])
+			return
+		case "doctor":
+			runDoctor(os.Args[2:])
+			return
+		case "gaps":
+			runGaps(os.Args[2:])
+			return
+		case "libraries":
+			runLibraries(os.Args[2:])
+			return
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "query":
+			runQuery(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Usage = usage
 	flag.Parse()
 
-	createRequiredDirectories()
+	setupGeneration()
 
 	date := time.Now().UTC()
 
@@ -73,84 +130,65 @@ func main() {
 }
 
 // generateYearlyPlaylist aggregates monthly playlists for a given year,
-// sums duplicate track counts, populates missing YT info, sorts by play count,
-// and writes the top 100 tracks to a yearly HTML page named "<year>.html".
-func generateYearlyPlaylist(year int, monthlyPlaylists []*nova.Playlist) {
-	// Aggregate tracks by key.
-	trackMap := make(map[string]*nova.Track)
-	for _, pl := range monthlyPlaylists {
-		if pl.Year != year {
-			continue
-		}
-		for _, t := range pl.Tracks {
-			key := t.Key()
-			if existing, ok := trackMap[key]; ok {
-				existing.Count += t.Count
-			} else {
-				// Copy available info, including any YTMusicInfo if present.
-				trackMap[key] = &nova.Track{
-					Artist:      t.Artist,
-					Title:       t.Title,
-					ImgURL:      t.ImgURL,
-					SpotifyURL:  t.SpotifyURL,
-					Count:       t.Count,
-					YTMusicInfo: t.YTMusicInfo,
-				}
-			}
-		}
-	}
-
-	// Convert the map to a slice.
-	var aggregatedTracks []*nova.Track
-	for _, t := range trackMap {
-		aggregatedTracks = append(aggregatedTracks, t)
-	}
-	// Sort tracks by play count descending.
-	sort.Slice(aggregatedTracks, func(i, j int) bool {
-		return aggregatedTracks[i].Count > aggregatedTracks[j].Count
-	})
-
-	// // Keep only the top 100 tracks.
-	// if len(aggregatedTracks) > 100 {
-	// 	aggregatedTracks = aggregatedTracks[:100]
-	// }
-
+// sums duplicate track counts and sorts by play count.
+// Its page is named "<year>.html".
+func generateYearlyPlaylist(year int, monthlyPlaylists []*nova.Playlist) *nova.Playlist {
 	// Create the yearly playlist.
 	yearlyPlaylist := &nova.Playlist{
-		Tracks: aggregatedTracks,
+		Tracks: chartTracks(year, monthlyPlaylists),
 		Year:   year,
 		Name:   strconv.Itoa(year),
 	}
+	return yearlyPlaylist
+}
 
-	// Populate YouTube info for each track (if missing).
-	if err := yearlyPlaylist.PopulateYTIDs(); err != nil {
-		log.Println("Error populating YT info for yearly playlist:", err)
+// generateAllTimePlaylist aggregates tracks from all playlists (all years)
+// without limiting the number of entries.
+func generateAllTimePlaylist(monthlyPlaylists []*nova.Playlist) *nova.Playlist {
+	// Create the All Times playlist (using Year==0 as a special marker).
+	allTimesPlaylist := &nova.Playlist{
+		Tracks: chartTracks(0, monthlyPlaylists),
+		Year:   0,
+		Name:   "All Times",
 	}
+	return allTimesPlaylist
+}
 
-	// Generate the HTML page using the existing template.
-	htmlData, err := yearlyPlaylist.ToHTML()
-	if err != nil {
-		log.Fatal("Error generating yearly HTML:", err)
+// populateChart populates the missing YT info of the tracks of a yearly or
+// all-time chart.
+func populateChart(chart *nova.Playlist) {
+	if err := chart.PopulateYTIDsWithProgress(printYTProgress); err != nil {
+		log.Println("Error populating YT info for", chart.Title(), err)
 	}
-	// Save the yearly HTML file as "<year>.html"
-	filename := filepath.Join("web", strconv.Itoa(year)+".html")
-	if err := os.WriteFile(filename, htmlData, os.ModePerm); err != nil {
-		log.Fatal("Error writing yearly HTML file:", err)
+	if err := chart.PopulateYTArtistIDs(printYTProgress); err != nil {
+		log.Println("Error populating YT artists for", chart.Title(), err)
 	}
-	fmt.Println("Generated yearly playlist HTML:", filename)
 }
 
-// generateAllTimePlaylist aggregates tracks from all playlists (all years)
-// without limiting the number of entries.
-func generateAllTimePlaylist(monthlyPlaylists []*nova.Playlist) {
-	// Aggregate all tracks regardless of year.
+// chartTracks sums the plays of the tracks of the monthly playlists of year,
+// of all years when it's 0, sorted by play count. A store able to do it, like
+// the database, is queried instead.
+func chartTracks(year int, monthlyPlaylists []*nova.Playlist) []*nova.Track {
+	if store, ok := nova.Playlists.(nova.ChartStore); ok {
+		tracks, err := store.Chart(year)
+		if err == nil {
+			return tracks
+		}
+		log.Println("Error querying the chart, aggregating the monthly playlists:", err)
+	}
+
+	// Aggregate tracks by key.
 	trackMap := make(map[string]*nova.Track)
 	for _, pl := range monthlyPlaylists {
+		if year != 0 && pl.Year != year {
+			continue
+		}
 		for _, t := range pl.Tracks {
 			key := t.Key()
 			if existing, ok := trackMap[key]; ok {
 				existing.Count += t.Count
 			} else {
+				// Copy available info, including any YTMusicInfo if present.
 				trackMap[key] = &nova.Track{
 					Artist:      t.Artist,
 					Title:       t.Title,
@@ -158,43 +196,22 @@ func generateAllTimePlaylist(monthlyPlaylists []*nova.Playlist) {
 					SpotifyURL:  t.SpotifyURL,
 					Count:       t.Count,
 					YTMusicInfo: t.YTMusicInfo,
+					YTArtistID:  t.YTArtistID,
 				}
 			}
 		}
 	}
 
+	// Convert the map to a slice.
 	var aggregatedTracks []*nova.Track
 	for _, t := range trackMap {
 		aggregatedTracks = append(aggregatedTracks, t)
 	}
-
 	// Sort tracks by play count descending.
 	sort.Slice(aggregatedTracks, func(i, j int) bool {
 		return aggregatedTracks[i].Count > aggregatedTracks[j].Count
 	})
-
-	// Create the All Times playlist (using Year==0 as a special marker).
-	allTimesPlaylist := &nova.Playlist{
-		Tracks: aggregatedTracks,
-		Year:   0,
-		Name:   "All Times",
-	}
-
-	// Populate YouTube info.
-	if err := allTimesPlaylist.PopulateYTIDs(); err != nil {
-		log.Println("Error populating YT info for All Times playlist:", err)
-	}
-
-	// Generate the HTML page.
-	htmlData, err := allTimesPlaylist.ToHTML()
-	if err != nil {
-		log.Fatal("Error generating All Times HTML:", err)
-	}
-	filename := filepath.Join("web", "AllTimes.html")
-	if err := os.WriteFile(filename, htmlData, os.ModePerm); err != nil {
-		log.Fatal("Error writing All Times HTML file:", err)
-	}
-	fmt.Println("Generated All Times playlist HTML:", filename)
+	return aggregatedTracks
 }
 
 func execute(month int, year int, shouldGenerate bool) {
@@ -209,12 +226,6 @@ func execute(month int, year int, shouldGenerate bool) {
 	}
 	fmt.Println("Processing", firstDayOfMonth, "to", lastDayOfMonth)
 
-	dateStr := nova.MonthEnglishName(time.Month(month)) + "-" + strconv.Itoa(date.Year())
-	monthlyPlaylist := nova.Playlist{
-		Name:  dateStr,
-		Year:  date.Year(),
-		Month: int(date.Month()),
-	}
 	_, err := nova.LoadYTMusicCache()
 	if err != nil {
 		log.Fatal(fmt.Errorf("Failed to load the YT music cache - %w", err))
@@ -223,17 +234,8 @@ func execute(month int, year int, shouldGenerate bool) {
 
 	// if the user passed a -fetch flag, run the code, otherwise exit
 	if *fetchFlag {
-		var err error
-		playlists, err := nova.GetPlaylists(firstDayOfMonth, lastDayOfMonth)
+		monthlyPlaylist, err := fetchMonthlyPlaylist(firstDayOfMonth, lastDayOfMonth)
 		if err != nil {
-			log.Fatalf("Something went wrong trying to get the playlists from %s to %s - %v", firstDayOfMonth, lastDayOfMonth, err)
-		}
-		for _, playlist := range playlists {
-			monthlyPlaylist.AddTracks(playlist.Tracks)
-		}
-		monthlyPlaylist.Sort()
-		monthlyPlaylist.PopulateYTIDs()
-		if err := monthlyPlaylist.SaveToDisk(); err != nil {
 			log.Fatal(err)
 		}
 		fmt.Println()
@@ -244,77 +246,285 @@ func execute(month int, year int, shouldGenerate bool) {
 	}
 
 	if *genFlag {
-		// generate the HTML pages
-		// look for all the playlists in the data directory
-		files, err := os.ReadDir(nova.PlaylistDataPath)
+		if err := generateSite(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+}
+
+// fetchMonthlyPlaylist fetches the daily playlists from start until end
+// excluded, like nova.GetPlaylists, and saves the monthly playlist of their
+// month aggregating them.
+func fetchMonthlyPlaylist(start, end time.Time) (*nova.Playlist, error) {
+	monthlyPlaylist := &nova.Playlist{
+		Name:  nova.MonthEnglishName(start.Month()) + "-" + strconv.Itoa(start.Year()),
+		Year:  start.Year(),
+		Month: int(start.Month()),
+	}
+	playlists, err := nova.GetPlaylists(start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the playlists from %s to %s - %w", start, end, err)
+	}
+	for _, playlist := range playlists {
+		monthlyPlaylist.AddTracks(playlist.Tracks)
+	}
+	monthlyPlaylist.Sort()
+	if err := monthlyPlaylist.PopulateYTIDsWithProgress(printYTProgress); err != nil {
+		log.Println("Error populating YT info for monthly playlist:", err)
+	}
+	if err := monthlyPlaylist.PopulateYTArtistIDs(printYTProgress); err != nil {
+		log.Println("Error populating YT artists for monthly playlist:", err)
+	}
+	if err := monthlyPlaylist.SaveToDisk(); err != nil {
+		return nil, err
+	}
+	return monthlyPlaylist, nil
+}
+
+// setupGeneration applies the flags changing how the site is generated
+// and creates the directories it needs.
+func setupGeneration() {
+	if *templatesFlag != "" {
+		renderer, err := nova.NewRenderer(nova.DefaultTemplates, os.DirFS(*templatesFlag))
 		if err != nil {
-			log.Fatal(fmt.Errorf("failed to read path %s - %v", nova.PlaylistDataPath, err))
+			log.Fatal(fmt.Errorf("Failed to load the templates from %s - %w", *templatesFlag, err))
 		}
-		index := &Index{Playlists: make(map[*nova.Playlist]string)}
+		nova.Templates = renderer
+	}
+	nova.VendorLibraries = *vendorFlag
+	useDatabase()
+	setupSiteTrees()
+	warnLegacyLayout()
 
-		playlists := []*nova.Playlist{}
-		for _, file := range files {
-			if file.IsDir() {
-				continue
-			}
-			if !strings.HasPrefix(file.Name(), "playlist-") && !strings.HasSuffix(file.Name(), ".gob") {
-				continue
-			}
-			playlist, err := nova.LoadPlaylistFromFile(filepath.Join(nova.PlaylistDataPath, file.Name()))
-			if err != nil {
-				log.Fatal(fmt.Errorf("failed to load playlist at path %s, %v", file.Name(), err))
-			}
-			fmt.Println("Playlist", playlist.Name, "loaded")
-			playlists = append(playlists, playlist)
+	createRequiredDirectories()
+}
+
+// generateSite generates the HTML pages, the exports, the feeds and the API
+// of the site in -out from all the playlists in the data directory.
+// Only the outputs whose inputs changed since the last build are generated.
+func generateSite() error {
+	// generate the HTML pages
+	// look for all the monthly playlists in the store
+	keys, err := nova.Playlists.List(nova.MonthlyPlaylists)
+	if err != nil {
+		return err
+	}
+	index := &Index{Playlists: make(map[*nova.Playlist]string)}
+	build := newSiteBuild(*outFlag)
+
+	playlists := []*nova.Playlist{}
+	for _, key := range keys {
+		playlist, err := nova.Playlists.Load(key)
+		if err != nil {
+			return fmt.Errorf("failed to load the playlist %s - %w", key, err)
 		}
-		// sort the playlists by year, month
-		sort.Slice(playlists, func(i, j int) bool {
-			if playlists[i].Year == playlists[j].Year {
-				return playlists[i].Month < playlists[j].Month
-			}
-			return playlists[i].Year < playlists[j].Year
-		})
+		if build.gobHashes[playlist], err = playlistHash(key, playlist); err != nil {
+			return err
+		}
+		fmt.Println("Playlist", playlist.Name, "loaded")
+		playlists = append(playlists, playlist)
+	}
+	// sort the playlists by year, month
+	sort.Slice(playlists, func(i, j int) bool {
+		if playlists[i].Year == playlists[j].Year {
+			return playlists[i].Month < playlists[j].Month
+		}
+		return playlists[i].Year < playlists[j].Year
+	})
 
-		for i, playlist := range playlists {
-			if i > 0 {
-				playlist.PreviousPlaylist = playlists[i-1]
-				playlists[i-1].NextPlaylist = playlist
-			}
+	for i, playlist := range playlists {
+		if i > 0 {
+			playlist.PreviousPlaylist = playlists[i-1]
+			playlists[i-1].NextPlaylist = playlist
 		}
+	}
 
-		for _, playlist := range playlists {
-			htmlFilename := filepath.Join("web", playlist.Name+".html")
-			htmlF, err := os.Create(htmlFilename)
-			if err != nil {
-				log.Fatal(err)
-			}
-			data, err := playlist.ToHTML()
-			if err != nil {
-				log.Fatal(err)
-			}
-			htmlF.Write(data)
-			htmlF.Close()
-			fmt.Println("Generated HTML file", htmlFilename)
-			index.Playlists[playlist] = playlist.Name + ".html"
+	// the heatmaps are drawn from the daily playlists
+	daily, err := dailyPlaylistHashes()
+	if err != nil {
+		return err
+	}
+	// the assets come with the binary, they're written even when the site
+	// is up to date so the ones of a new version replace the previous ones
+	if err := writeStaticAssets(); err != nil {
+		return err
+	}
+	if !build.stale(manifestSiteKey, append(build.playlistsInputs("site", playlists), daily...)) {
+		fmt.Println("The site is up to date, pass -full to generate it anyway")
+		return nil
+	}
+
+	// the pages to render, once all the playlists are ready
+	var pages []*nova.Playlist
+	var upToDate int
+	for _, playlist := range playlists {
+		// resolve the artists now so rendering never hits the network
+		if err := playlist.PopulateYTArtistIDs(printYTProgress); err != nil {
+			log.Println("Error populating YT artists for", playlist.Name, err)
 		}
+		index.Playlists[playlist] = playlist.Basename() + ".html"
+		if !build.stale(playlist.Basename()+".html", build.monthlyInputs(playlist)) {
+			upToDate++
+			continue
+		}
+		pages = append(pages, playlist)
+	}
+	if upToDate > 0 {
+		fmt.Println(upToDate, "monthly pages are up to date")
+	}
 
-		if err = index.SaveToDisk(); err != nil {
-			log.Fatal(err)
+	catalog := nova.NewCatalog(playlists)
+	heatmaps, err := loadPlayHeatmaps(catalog)
+	if err != nil {
+		return err
+	}
+	if heatmaps.Introductions.Total() > 0 {
+		index.Introductions = heatmaps.Introductions
+	}
+	if err = index.SaveToDisk(); err != nil {
+		return err
+	}
+
+	if err := writeFeeds(build, playlists); err != nil {
+		return err
+	}
+
+	// publish the schema of the .json exports so they can be validated
+	if build.stale("playlist.schema.json", []string{"schema", contentHash(nova.PlaylistJSONSchema)}) {
+		if err := writeSiteFile(filepath.Join(*outFlag, "playlist.schema.json"), nova.PlaylistJSONSchema); err != nil {
+			return err
 		}
+	}
 
-		generateAllTimePlaylist(playlists)
+	// the yearly and all-time charts are only looked up on YT Music when
+	// their page or their API document is generated
+	addChart := func(chart *nova.Playlist) {
+		inputs := build.chartInputs(chart, playlists)
+		pageStale := build.stale(chart.Basename()+".html", inputs)
+		if build.stale(apiPlaylistPath(chart), inputs) || pageStale {
+			populateChart(chart)
+		}
+		if pageStale {
+			pages = append(pages, chart)
+		}
+	}
+	allTimesPlaylist := generateAllTimePlaylist(playlists)
+	addChart(allTimesPlaylist)
 
-		// Aggregate monthly playlists into yearly playlists.
-		yearSet := make(map[int]bool)
-		for _, pl := range playlists {
-			yearSet[pl.Year] = true
+	// Aggregate monthly playlists into yearly playlists.
+	yearSet := make(map[int]bool)
+	for _, pl := range playlists {
+		yearSet[pl.Year] = true
+	}
+	var yearlyPlaylists []*nova.Playlist
+	for yr := range yearSet {
+		yearlyPlaylist := generateYearlyPlaylist(yr, playlists)
+		yearlyPlaylists = append(yearlyPlaylists, yearlyPlaylist)
+		addChart(yearlyPlaylist)
+	}
+	sort.Slice(yearlyPlaylists, func(i, j int) bool {
+		return yearlyPlaylists[i].Year < yearlyPlaylists[j].Year
+	})
+
+	// a page failing to render doesn't stop the build, the errors are reported at the end
+	var errs []error
+	for _, page := range renderPlaylistPages(pages) {
+		build.discard(page.output)
+		errs = append(errs, page.err)
+	}
+
+	catalog.SetHeatmaps(heatmaps)
+	if err := writeAPI(build, playlists, yearlyPlaylists, allTimesPlaylist, catalog); err != nil {
+		return err
+	}
+	if err := writeSearchIndex(build, playlists, catalog); err != nil {
+		return err
+	}
+	if err := writeTrackPages(build, catalog); err != nil {
+		errs = append(errs, err)
+	}
+	if err := writeArtistPages(build, catalog); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		// don't let the next build stop early, the failed outputs need to be generated
+		build.discard(manifestSiteKey)
+	}
+	if err := build.save(); err != nil {
+		return fmt.Errorf("failed to save the build manifest - %w", err)
+	}
+	return errors.Join(errs...)
+}
+
+// writePlaylistExports writes the playlist in the other supported formats
+// next to its HTML page, using the same name with a different extension.
+func writePlaylistExports(htmlFilename string, playlist *nova.Playlist) error {
+	basename := strings.TrimSuffix(htmlFilename, filepath.Ext(htmlFilename))
+	exports := []struct {
+		ext    string
+		encode func() ([]byte, error)
+	}{
+		{".m3u8", playlist.ToM3U8},
+		{".xspf", playlist.ToXSPF},
+		{".jspf", playlist.ToJSPF},
+		{".json", playlist.ToJSON},
+		{".csv", playlist.ToCSV},
+	}
+	for _, export := range exports {
+		data, err := export.encode()
+		if err != nil {
+			return fmt.Errorf("failed to generate the %s export of %s - %w", export.ext, playlist.Title(), err)
 		}
-		for yr := range yearSet {
-			generateYearlyPlaylist(yr, playlists)
+		if err := writeSiteFile(basename+export.ext, data); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// printYTProgress reports the YT Music lookups progress on a single line.
+func printYTProgress(p nova.YTProgress) {
+	fmt.Printf("\rYT Music lookups: %s", p)
+	if p.Done == p.Total {
+		fmt.Println()
+	}
+}
 
+// writeStaticAssets copies the assets the pages depend on to the site so it's
+// self-contained, and the third-party libraries when vendoring them.
+func writeStaticAssets() error {
+	for _, lib := range nova.Libraries {
+		if lib.Integrity == "" && !*vendorFlag {
+			fmt.Printf("%s has no integrity in libraries.sum and is loaded unchecked, run %s libraries > libraries.sum and rebuild\n", lib.Name, os.Args[0])
+		}
+	}
+	// the default site is generated next to the assets
+	if filepath.Clean(*outFlag) != "web" {
+		if err := nova.CopyStaticAssets(*outFlag); err != nil {
+			return fmt.Errorf("failed to copy the static assets - %w", err)
+		}
+		fmt.Println("Copied the static assets to", *outFlag)
 	}
+	if *vendorFlag {
+		if err := nova.VendorLibrariesTo(*outFlag); err != nil {
+			return fmt.Errorf("failed to vendor the libraries - %w", err)
+		}
+		fmt.Println("Vendored the libraries in", filepath.Join(*outFlag, "vendor"))
+	}
+	return nil
+}
 
+// warnLegacyLayout warns about the playlists left in the legacy layout,
+// which aren't part of the store anymore.
+func warnLegacyLayout() {
+	store, ok := nova.Playlists.(*nova.FSStore)
+	if !ok {
+		return
+	}
+	if legacy, _ := store.LegacyFiles(); len(legacy) > 0 {
+		fmt.Printf("%d playlists are in the legacy layout and are ignored, run %s migrate to move them\n", len(legacy), os.Args[0])
+	}
 }
 
 func createRequiredDirectories() {
@@ -325,8 +535,8 @@ func createRequiredDirectories() {
 		}
 	}
 
-	if _, err := os.Stat("web"); os.IsNotExist(err) {
-		if err := os.Mkdir("web", 0755); err != nil {
+	if _, err := os.Stat(*outFlag); os.IsNotExist(err) {
+		if err := os.MkdirAll(*outFlag, 0755); err != nil {
 			log.Fatal("Error creating the web directory:", err)
 		}
 	}
@@ -337,7 +547,7 @@ type PlaylistFile struct {
 	Month        int
 	Path         string
 	ThumbnailURL string
-	FeaturedText string
+	TopTrack     *nova.Track
 }
 
 func (p *PlaylistFile) Title() string {
@@ -354,58 +564,28 @@ type Index struct {
 	PlaylistFiles []*PlaylistFile
 	YearLinks     []*YearLink
 	Playlists     map[*nova.Playlist]string
+	// Introductions are the first plays of the tracks, nil without daily playlists.
+	Introductions *nova.Heatmap
 }
 
-var HTMLIndexTmpl = `
-<!DOCTYPE html>
-<html>
-<head>
-	<title>Radio Nova - Playlists</title>
-	<link rel="stylesheet" type="text/css" href="index.css">
-	<link href="https://fonts.googleapis.com/css2?family=Open+Sans&display=swap" rel="stylesheet">
-</head>
-<body>
-	<h1>Radio Nova - Playlists</h1>
-	<h2>Yearly Playlists</h2>
-	<ul class="playlists">
-		{{range .YearLinks}}
-			<li class="playlist"><a href="{{.Filename}}">{{if .Name}}{{.Name}}{{else}}{{.Year}}{{end}}</a></li>
-		{{end}}
-	</ul>
-	<h2>Monthly Playlists</h2>
-	<ul class="playlists">
-		{{range .PlaylistFiles}}
-			<li class="playlist" data-featured="{{.FeaturedText}}">
-				<a href="{{.Path}}"><img src="{{.ThumbnailURL}}" class="artwork" alt="{{.FeaturedText}}"/>{{.Title}}</a>
-			</li>
-		{{end}}
-	</ul>
-</body>
-</html>
-`
-
 func (idx *Index) ToHTML() ([]byte, error) {
-	t, err := template.New("index").Parse(HTMLIndexTmpl)
-	if err != nil {
-		return nil, err
-	}
-	var buf bytes.Buffer
-	err = t.Execute(&buf, idx)
-	if err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
+	return nova.Templates.Render("index.html", idx)
 }
 
+// SaveToDisk writes the index page in all the trees of the site.
 func (idx *Index) SaveToDisk() error {
 	// Populate monthly playlist files.
 	for playlist, path := range idx.Playlists {
+		if len(playlist.Tracks) == 0 {
+			fmt.Println("Skipping the empty playlist", playlist.Name, "in the index, see nova doctor")
+			continue
+		}
 		pf := &PlaylistFile{
 			Year:         playlist.Year,
 			Month:        playlist.Month,
 			Path:         path,
 			ThumbnailURL: playlist.Tracks[0].ThumbURL(),
-			FeaturedText: fmt.Sprintf("Top track: %s by %s", playlist.Tracks[0].Title, playlist.Tracks[0].Artist),
+			TopTrack:     playlist.Tracks[0],
 		}
 		idx.PlaylistFiles = append(idx.PlaylistFiles, pf)
 	}
@@ -443,11 +623,14 @@ func (idx *Index) SaveToDisk() error {
 		return idx.YearLinks[i].Year > idx.YearLinks[j].Year
 	})
 
-	html, err := idx.ToHTML()
-	if err != nil {
-		return err
+	for _, tree := range siteTrees {
+		html, err := tree.renderer.Render("index.html", idx)
+		if err != nil {
+			return err
+		}
+		if err := writeSiteFile(filepath.Join(tree.dir, "index.html"), html); err != nil {
+			return err
+		}
 	}
-
-	filename := filepath.Join("web", "index.html")
-	return os.WriteFile(filename, html, os.ModePerm)
+	return nil
 }
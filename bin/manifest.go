@@ -0,0 +1,258 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// manifestVersion is part of every input hash, bump it when the generated
+// files change without their inputs changing (e.g. a new export format).
+const manifestVersion = 3
+
+// manifestSiteKey is the entry of the index, generated from all the charts and
+// the daily playlists. Nothing else can change when it's up to date, the
+// build stops early. The other outputs have their own entry.
+const manifestSiteKey = "index.html"
+
+// buildManifest records the hash of the inputs each output of the site was
+// generated from, so the outputs whose inputs didn't change can be skipped.
+// It's stored in the site directory as .build-manifest.json.
+type buildManifest struct {
+	Version int               `json:"version"`
+	Outputs map[string]string `json:"outputs"`
+
+	dir string
+}
+
+func loadBuildManifest(dir string) *buildManifest {
+	m := &buildManifest{Version: manifestVersion, Outputs: make(map[string]string), dir: dir}
+	data, err := os.ReadFile(m.path())
+	if err != nil {
+		return m
+	}
+	var saved buildManifest
+	// an unreadable or outdated manifest is the same as no manifest, everything gets generated
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Version != manifestVersion || saved.Outputs == nil {
+		return m
+	}
+	m.Outputs = saved.Outputs
+	return m
+}
+
+func (m *buildManifest) path() string {
+	return filepath.Join(m.dir, ".build-manifest.json")
+}
+
+// upToDate reports if output was generated from inputs with the given hash and is still there.
+func (m *buildManifest) upToDate(output, hash string) bool {
+	if *fullFlag || m.Outputs[output] != hash {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(m.dir, output))
+	return err == nil
+}
+
+func (m *buildManifest) record(output, hash string) {
+	m.Outputs[output] = hash
+}
+
+func (m *buildManifest) save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return nova.WriteFileAtomic(m.path(), 0644, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// inputsHash hashes the inputs of an output along with what all the outputs depend on:
+// the templates, the YT Music cache and the options changing the generated files.
+func inputsHash(cacheRevision string, inputs ...string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00%t\x00%s\x00", manifestVersion, nova.Templates.Version(), cacheRevision, *baseURLFlag, *vendorFlag, *langFlag)
+	for _, input := range inputs {
+		fmt.Fprintf(h, "%s\x00", input)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// playlistHash is the hash of a playlist of the store: the hash of its file
+// in a FSStore, of its encoding otherwise.
+func playlistHash(key nova.PlaylistKey, playlist *nova.Playlist) (string, error) {
+	if store, ok := nova.Playlists.(*nova.FSStore); ok {
+		path, err := store.File(key)
+		if err != nil {
+			return "", err
+		}
+		return fileHash(path)
+	}
+	h := sha256.New()
+	if err := gob.NewEncoder(h).Encode(playlist); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dailyPlaylistHashes are the hashes of the daily playlists of the store.
+func dailyPlaylistHashes() ([]string, error) {
+	keys, err := nova.Playlists.List(nova.DailyPlaylists)
+	if err != nil {
+		return nil, err
+	}
+	_, inFiles := nova.Playlists.(*nova.FSStore)
+	hashes := make([]string, 0, len(keys))
+	for _, key := range keys {
+		// the files are hashed without being loaded
+		var playlist *nova.Playlist
+		if !inFiles {
+			if playlist, err = nova.Playlists.Load(key); err != nil {
+				return nil, fmt.Errorf("failed to load the playlist %s - %w", key, err)
+			}
+		}
+		hash, err := playlistHash(key, playlist)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// fileHash is the hash of the content of a file.
+func fileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return contentHash(data), nil
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// heatmapHash is the hash of the counts of a heatmap, empty without one.
+func heatmapHash(h *nova.Heatmap) string {
+	if h == nil {
+		return ""
+	}
+	return contentHash([]byte(fmt.Sprint(*h)))
+}
+
+// siteBuild tells which outputs of the site need to be generated.
+type siteBuild struct {
+	manifest *buildManifest
+	// cacheRevision is the revision of the YT Music cache at the start of the build.
+	cacheRevision string
+	gobHashes     map[*nova.Playlist]string
+	// checked are the inputs of the outputs checked by the build,
+	// whether they were generated or up to date.
+	checked map[string][]string
+}
+
+func newSiteBuild(dir string) *siteBuild {
+	return &siteBuild{
+		manifest:      loadBuildManifest(dir),
+		cacheRevision: nova.YTMusic.Revision(),
+		gobHashes:     make(map[*nova.Playlist]string),
+		checked:       make(map[string][]string),
+	}
+}
+
+// stale reports if the output needs to be generated from the given inputs.
+func (b *siteBuild) stale(output string, inputs []string) bool {
+	b.checked[output] = inputs
+	return !b.manifest.upToDate(output, inputsHash(b.cacheRevision, inputs...))
+}
+
+// discard forgets an output generated by the build, e.g. when it failed.
+func (b *siteBuild) discard(output string) {
+	delete(b.checked, output)
+	delete(b.manifest.Outputs, output)
+}
+
+// monthlyInputs are the inputs of a monthly page: its chart, the previous one
+// for the ranking changes and the name of the next one for the navigation.
+func (b *siteBuild) monthlyInputs(playlist *nova.Playlist) []string {
+	inputs := []string{"monthly", b.gobHashes[playlist], "", ""}
+	if playlist.PreviousPlaylist != nil {
+		inputs[2] = b.gobHashes[playlist.PreviousPlaylist]
+	}
+	if playlist.NextPlaylist != nil {
+		inputs[3] = playlist.NextPlaylist.Name
+	}
+	return inputs
+}
+
+// chartInputs are the inputs of the page and the API document of a chart: the
+// ones of a monthly page, or the charts summed up by a yearly or all-time one.
+func (b *siteBuild) chartInputs(chart *nova.Playlist, monthly []*nova.Playlist) []string {
+	if playlistKind(chart) == "monthly" {
+		return b.monthlyInputs(chart)
+	}
+	var charts []*nova.Playlist
+	for _, playlist := range monthly {
+		if chart.Year == 0 || playlist.Year == chart.Year {
+			charts = append(charts, playlist)
+		}
+	}
+	return b.playlistsInputs(playlistKind(chart), charts)
+}
+
+// trackInputs are the charts a track was in, its page and API document are
+// generated from them.
+func (b *siteBuild) trackInputs(track *nova.CatalogTrack) []string {
+	inputs := []string{"track", track.ID}
+	for _, appearance := range track.Appearances {
+		inputs = append(inputs, b.gobHashes[appearance.Playlist])
+	}
+	return inputs
+}
+
+// artistInputs are the tracks of an artist and the charts they were in.
+func (b *siteBuild) artistInputs(artist *nova.CatalogArtist) []string {
+	inputs := []string{"artist", artist.Slug}
+	charts := make(map[*nova.Playlist]bool)
+	for _, track := range artist.Tracks {
+		inputs = append(inputs, track.ID)
+		for _, appearance := range track.Appearances {
+			if !charts[appearance.Playlist] {
+				charts[appearance.Playlist] = true
+				inputs = append(inputs, b.gobHashes[appearance.Playlist])
+			}
+		}
+	}
+	return inputs
+}
+
+// playlistsInputs are the inputs of an output aggregating charts.
+func (b *siteBuild) playlistsInputs(kind string, playlists []*nova.Playlist) []string {
+	inputs := []string{kind}
+	for _, playlist := range playlists {
+		inputs = append(inputs, b.gobHashes[playlist])
+	}
+	return inputs
+}
+
+// save records the checked outputs in the manifest.
+func (b *siteBuild) save() error {
+	// the lookups of the build were added to the cache and the next build
+	// starts from this revision: the outputs which were up to date are
+	// recorded again with it, or they would all be generated again
+	revision := nova.YTMusic.Revision()
+	for output, inputs := range b.checked {
+		b.manifest.record(output, inputsHash(revision, inputs...))
+	}
+	return b.manifest.save()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// runMigrate implements the migrate command: it moves the playlists of the
+// legacy layout, or of another data directory, to where the store keeps them.
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", nova.PlaylistDataPath, "directory of the playlists to move, e.g. a data directory created by running the command from bin/")
+	dryRun := fs.Bool("dry-run", false, "print the moves without doing them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s migrate:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	store := &nova.FSStore{}
+	paths, err := playlistFiles(*from)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var moved, duplicates, failed int
+	for _, path := range paths {
+		if _, ok := store.KeyOf(path); ok {
+			// already in place
+			continue
+		}
+		playlist, err := nova.LoadPlaylistFromFile(path)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		key := playlist.StoreKey()
+		if key == (nova.PlaylistKey{}) {
+			fmt.Printf("✗ %s: the playlist has no date nor name\n", path)
+			failed++
+			continue
+		}
+		if nova.IsLegacyFilename(filepath.Base(path)) && playlist.OldFilename() != filepath.Base(path) {
+			fmt.Printf("✗ %s: the playlist is named %q, check it with nova doctor\n", path, playlist.Name)
+			failed++
+			continue
+		}
+
+		// the format is kept, nova convert changes it
+		format, _ := nova.StorageFormatOf(path)
+		dest := store.PathFormat(key, format)
+		if _, err := os.Stat(dest); err == nil {
+			same, err := sameFiles(path, dest)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if !same {
+				fmt.Printf("✗ %s: %s already exists with a different content, compare them and remove one\n", path, dest)
+				failed++
+				continue
+			}
+			fmt.Printf("%s is a copy of %s, removing it\n", path, dest)
+			if !*dryRun {
+				if err := os.Remove(path); err != nil {
+					log.Fatal(err)
+				}
+			}
+			duplicates++
+			continue
+		}
+
+		fmt.Printf("%s → %s\n", path, dest)
+		if !*dryRun {
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				log.Fatal(err)
+			}
+			if err := os.Rename(path, dest); err != nil {
+				log.Fatal(err)
+			}
+		}
+		moved++
+	}
+
+	fmt.Printf("%d playlists moved, %d copies removed, %d left in place\n", moved, duplicates, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// playlistFiles lists the playlist files, in any storage format, in dir and
+// its subdirectories.
+func playlistFiles(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isPlaylistFile(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the playlists in %s - %w", dir, err)
+	}
+	return paths, nil
+}
+
+// isPlaylistFile reports if path is named like a playlist in one of the
+// storage formats, unlike daemon-status.json for instance.
+func isPlaylistFile(path string) bool {
+	_, ok := nova.StorageFormatOf(path)
+	return ok && strings.HasPrefix(filepath.Base(path), "playlist-")
+}
+
+func sameFiles(a, b string) (bool, error) {
+	dataA, err := os.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	dataB, err := os.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(dataA, dataB), nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// runQuery implements the query command: it groups the plays of the daily
+// playlists selected by the filters and prints them.
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	artist := fs.String("artist", "", "only the plays of the artists containing this, ignoring the case")
+	title := fs.String("title", "", "only the plays of the titles containing this, ignoring the case")
+	from := fs.String("from", "", "first day of the plays, as 2006-01-02, 2006-01 or 2006")
+	to := fs.String("to", "", "last day of the plays, as 2006-01-02, 2006-01 or 2006")
+	hours := fs.String("hour", "", "only the plays started during these hours, e.g. 2-5 for 2:00 to 4:59, 22-2 or 8,12")
+	weekdays := fs.String("weekday", "", "only the plays of these days of the week, e.g. sat,sun or mon-fri")
+	minPlays := fs.Int("min-plays", 1, "only the rows with at least this number of plays")
+	by := fs.String("by", "track", "group the plays by track, artist, month or hour")
+	format := fs.String("format", "table", "output format: table, json or csv")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s query:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	filter := &nova.PlayFilter{Artist: *artist, Title: *title}
+	var err error
+	if filter.From, err = parseQueryDay(*from, false); err != nil {
+		log.Fatal(fmt.Errorf("invalid -from - %w", err))
+	}
+	if filter.To, err = parseQueryDay(*to, true); err != nil {
+		log.Fatal(fmt.Errorf("invalid -to - %w", err))
+	}
+	if filter.Hours, err = parseHours(*hours); err != nil {
+		log.Fatal(fmt.Errorf("invalid -hour - %w", err))
+	}
+	if filter.Weekdays, err = parseWeekdays(*weekdays); err != nil {
+		log.Fatal(fmt.Errorf("invalid -weekday - %w", err))
+	}
+	group, ok := queryGroups[*by]
+	if !ok {
+		log.Fatalf("invalid -by %q, use track, artist, month or hour", *by)
+	}
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		log.Fatal(err)
+	}
+	plays, err := nova.LoadPlays(nova.Playlists, filter, paris)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rows := groupPlays(plays, group)
+	selected := rows[:0]
+	for _, row := range rows {
+		if row.Plays >= *minPlays {
+			selected = append(selected, row)
+		}
+	}
+
+	switch *format {
+	case "table":
+		err = printQueryTable(group, selected)
+	case "json":
+		err = printQueryJSON(group, selected)
+	case "csv":
+		err = printQueryCSV(group, selected)
+	default:
+		log.Fatalf("invalid -format %q, use table, json or csv", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// queryGroup is a way of grouping the plays in the rows of the query command.
+type queryGroup struct {
+	// columns are the names of the values of the key.
+	columns []string
+	key     func(p *nova.Play) []string
+	// id identifies the row of a play when its key isn't enough, the key
+	// of the first play of the row is shown.
+	id func(p *nova.Play) string
+	// byPlays sorts the rows by plays instead of by key.
+	byPlays bool
+	// tracks adds the number of different tracks to the rows.
+	tracks bool
+}
+
+var queryGroups = map[string]*queryGroup{
+	"track": {
+		columns: []string{"artist", "title"},
+		key:     func(p *nova.Play) []string { return []string{p.Track.Artist, p.Track.Title} },
+		id:      func(p *nova.Play) string { return p.Track.Key() },
+		byPlays: true,
+	},
+	"artist": {
+		columns: []string{"artist"},
+		key:     func(p *nova.Play) []string { return []string{p.Track.Artist} },
+		byPlays: true,
+		tracks:  true,
+	},
+	"month": {
+		columns: []string{"month"},
+		key:     func(p *nova.Play) []string { return []string{p.PlayedAt.Format("2006-01")} },
+		tracks:  true,
+	},
+	"hour": {
+		columns: []string{"hour"},
+		key:     func(p *nova.Play) []string { return []string{fmt.Sprintf("%02d", p.Track.Hour)} },
+		tracks:  true,
+	},
+}
+
+// queryRow are the plays of a group.
+type queryRow struct {
+	Key         []string
+	Plays       int
+	Tracks      int
+	First, Last time.Time
+	tracks      map[string]bool
+}
+
+func groupPlays(plays []*nova.Play, group *queryGroup) []*queryRow {
+	byKey := make(map[string]*queryRow)
+	var rows []*queryRow
+	for _, play := range plays {
+		key := group.key(play)
+		id := strings.Join(key, "|")
+		if group.id != nil {
+			id = group.id(play)
+		}
+		row := byKey[id]
+		if row == nil {
+			row = &queryRow{Key: key, First: play.PlayedAt, tracks: make(map[string]bool)}
+			byKey[id] = row
+			rows = append(rows, row)
+		}
+		row.Plays++
+		row.Last = play.PlayedAt
+		if !row.tracks[play.Track.Key()] {
+			row.tracks[play.Track.Key()] = true
+			row.Tracks++
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if group.byPlays && rows[i].Plays != rows[j].Plays {
+			return rows[i].Plays > rows[j].Plays
+		}
+		return strings.Join(rows[i].Key, "|") < strings.Join(rows[j].Key, "|")
+	})
+	return rows
+}
+
+func (group *queryGroup) header() []string {
+	header := append(append([]string(nil), group.columns...), "plays")
+	if group.tracks {
+		header = append(header, "tracks")
+	}
+	return append(header, "first", "last")
+}
+
+func (group *queryGroup) record(row *queryRow) []string {
+	record := append(append([]string(nil), row.Key...), strconv.Itoa(row.Plays))
+	if group.tracks {
+		record = append(record, strconv.Itoa(row.Tracks))
+	}
+	return append(record, row.First.Format("2006-01-02 15:04"), row.Last.Format("2006-01-02 15:04"))
+}
+
+func printQueryTable(group *queryGroup, rows []*queryRow) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(group.header(), "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(group.record(row), "\t"))
+	}
+	return w.Flush()
+}
+
+func printQueryCSV(group *queryGroup, rows []*queryRow) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write(group.header())
+	for _, row := range rows {
+		w.Write(group.record(row))
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func printQueryJSON(group *queryGroup, rows []*queryRow) error {
+	objects := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		object := map[string]any{
+			"plays": row.Plays,
+			"first": row.First,
+			"last":  row.Last,
+		}
+		if group.tracks {
+			object["tracks"] = row.Tracks
+		}
+		for i, column := range group.columns {
+			object[column] = row.Key[i]
+		}
+		objects = append(objects, object)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(objects)
+}
+
+// parseQueryDay parses a day, a month or a year. The last day of the month
+// or year is returned with last, the first one otherwise.
+func parseQueryDay(s string, last bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []struct {
+		layout string
+		years  int
+		months int
+	}{{"2006-01-02", 0, 0}, {"2006-01", 0, 1}, {"2006", 1, 0}} {
+		t, err := time.Parse(layout.layout, s)
+		if err != nil {
+			continue
+		}
+		if last && (layout.years > 0 || layout.months > 0) {
+			t = t.AddDate(layout.years, layout.months, -1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q isn't a day, month or year like 2023-03-14, 2023-03 or 2023", s)
+}
+
+// parseHours parses hours and ranges of hours like 2-5,22, a range including
+// its first hour but not its last one. The ranges can wrap around midnight.
+func parseHours(s string) (map[int]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	hours := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 || start > 23 {
+			return nil, fmt.Errorf("%q isn't an hour from 0 to 23", first)
+		}
+		if !isRange {
+			hours[start] = true
+			continue
+		}
+		end, err := strconv.Atoi(last)
+		if err != nil || end < 0 || end > 24 {
+			return nil, fmt.Errorf("%q isn't an hour from 0 to 24", last)
+		}
+		n := (end - start + 24) % 24
+		if end == 24 && start == 0 {
+			n = 24
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("the range %q has no hours", part)
+		}
+		for i := 0; i < n; i++ {
+			hours[(start+i)%24] = true
+		}
+	}
+	return hours, nil
+}
+
+// parseWeekdays parses days of the week and ranges of days like sat,sun or
+// mon-fri, by their English names or their first three letters.
+func parseWeekdays(s string) (map[time.Weekday]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	weekdays := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := parseWeekday(first)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if isRange {
+			if end, err = parseWeekday(last); err != nil {
+				return nil, err
+			}
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			weekdays[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return weekdays, nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(s)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("%q isn't a day of the week", s)
+}
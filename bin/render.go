@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+var workersFlag = flag.Int("workers", runtime.NumCPU(), "number of pages rendered concurrently")
+var langFlag = flag.String("lang", "en", "language of the pages at the root of the site (en or fr), the pages are also generated in each language in en/ and fr/")
+
+// siteTree is a directory of the site with the pages in a language.
+type siteTree struct {
+	dir      string
+	renderer *nova.Renderer
+	// root is the root of the site, the other trees only have the HTML pages.
+	root bool
+}
+
+// siteTrees are the root of the site in the -lang language,
+// followed by a tree per locale.
+var siteTrees []siteTree
+
+// setupSiteTrees localizes nova.Templates in the -lang language and sets up siteTrees.
+func setupSiteTrees() {
+	locale := nova.LocaleByLang(*langFlag)
+	if locale == nil {
+		log.Fatalf("unsupported -lang %q", *langFlag)
+	}
+	renderer, err := nova.Templates.Localized(locale, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	nova.Templates = renderer
+	siteTrees = []siteTree{{dir: *outFlag, renderer: renderer, root: true}}
+	for _, locale := range nova.Locales {
+		renderer, err := nova.Templates.Localized(locale, "../")
+		if err != nil {
+			log.Fatal(err)
+		}
+		siteTrees = append(siteTrees, siteTree{dir: filepath.Join(*outFlag, locale.Lang), renderer: renderer})
+	}
+	for _, tree := range siteTrees {
+		if err := os.MkdirAll(tree.dir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// renderError is an output that failed to be generated.
+type renderError struct {
+	output string
+	err    error
+}
+
+// renderPlaylistPages renders the HTML pages of the playlists in all the trees
+// of the site and their exports concurrently, and returns the pages that failed.
+func renderPlaylistPages(playlists []*nova.Playlist) []renderError {
+	return runRenderJobs(len(playlists)*len(siteTrees), func(i int) (string, error) {
+		playlist, tree := playlists[i/len(siteTrees)], siteTrees[i%len(siteTrees)]
+		output := playlist.Basename() + ".html"
+		filename := filepath.Join(tree.dir, output)
+		data, err := tree.renderer.Render("playlist.html", playlist)
+		if err != nil {
+			return output, fmt.Errorf("failed to render %s - %w", filename, err)
+		}
+		if err := writeSiteFile(filename, data); err != nil {
+			return output, err
+		}
+		if !tree.root {
+			return output, nil
+		}
+		if err := writePlaylistExports(filename, playlist); err != nil {
+			return output, err
+		}
+		fmt.Println("Generated HTML file", filename)
+		return output, nil
+	})
+}
+
+// runRenderJobs runs the n jobs with a pool of -workers goroutines
+// and collects the errors, it doesn't stop at the first one.
+func runRenderJobs(n int, job func(i int) (output string, err error)) []renderError {
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var failed []renderError
+	var wg sync.WaitGroup
+	for w := 0; w < max(*workersFlag, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if output, err := job(i); err != nil {
+					mu.Lock()
+					failed = append(failed, renderError{output: output, err: err})
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return failed
+}
+
+// writeSiteFile atomically writes a file of the site, so a failed build
+// never leaves a partially written page behind.
+func writeSiteFile(filename string, data []byte) error {
+	err := nova.WriteFileAtomic(filename, 0644, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s - %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// searchIndex is the content of web/search-index.json, queried by web/search.js.
+// It's kept compact: the tracks reference the charts by their position in Charts.
+type searchIndex struct {
+	Version int `json:"version"`
+	// Charts are the names of the monthly charts, chronologically.
+	Charts []string `json:"charts"`
+	// Tracks are [artist, title, id, [chart indexes], total count],
+	// sorted by total count.
+	Tracks [][]any `json:"tracks"`
+}
+
+// writeSearchIndex generates web/search-index.json from the catalog of the
+// monthly charts, and the search page querying it.
+func writeSearchIndex(build *siteBuild, monthly []*nova.Playlist, catalog *nova.Catalog) error {
+	if build.stale("search.html", []string{"search"}) {
+		page, err := nova.Templates.Render("search.html", nil)
+		if err != nil {
+			return fmt.Errorf("failed to render the search page - %w", err)
+		}
+		if err := writeSiteFile(filepath.Join(*outFlag, "search.html"), page); err != nil {
+			return err
+		}
+	}
+	if !build.stale("search-index.json", build.playlistsInputs("search", monthly)) {
+		return nil
+	}
+
+	index := searchIndex{Version: 1}
+	chartIndexes := make(map[*nova.Playlist]int, len(monthly))
+	for i, playlist := range monthly {
+		index.Charts = append(index.Charts, playlist.Basename())
+		chartIndexes[playlist] = i
+	}
+
+	for _, track := range catalog.SortedTracks() {
+		charts := make([]int, 0, len(track.Appearances))
+		for _, appearance := range track.Appearances {
+			charts = append(charts, chartIndexes[appearance.Playlist])
+		}
+		index.Tracks = append(index.Tracks, []any{track.Track.Artist, track.Track.Title, track.ID, charts, track.Track.Count})
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to encode the search index - %w", err)
+	}
+	filename := filepath.Join(*outFlag, "search-index.json")
+	if err := writeSiteFile(filename, data); err != nil {
+		return err
+	}
+	fmt.Println("Generated the search index:", filename)
+	return nil
+}
+
+// writeTrackPages generates a page per track under web/tracks/ listing
+// the monthly charts it was in, for the tracks whose charts or plays changed.
+func writeTrackPages(build *siteBuild, catalog *nova.Catalog) error {
+	for _, tree := range siteTrees {
+		if err := os.MkdirAll(filepath.Join(tree.dir, "tracks"), 0755); err != nil {
+			return err
+		}
+	}
+
+	var tracks []*nova.CatalogTrack
+	for _, track := range catalog.SortedTracks() {
+		if build.stale(path.Join("tracks", track.ID+".html"), append(build.trackInputs(track), heatmapHash(track.Heatmap))) {
+			tracks = append(tracks, track)
+		}
+	}
+	failed := runRenderJobs(len(tracks)*len(siteTrees), func(i int) (string, error) {
+		track, tree := tracks[i/len(siteTrees)], siteTrees[i%len(siteTrees)]
+		output := path.Join("tracks", track.ID+".html")
+		filename := filepath.Join(tree.dir, filepath.FromSlash(output))
+		data, err := tree.renderer.Render("track.html", track)
+		if err != nil {
+			return output, fmt.Errorf("failed to render %s - %w", filename, err)
+		}
+		return output, writeSiteFile(filename, data)
+	})
+	for _, page := range failed {
+		build.discard(page.output)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d track pages failed, the first one: %w", len(failed), failed[0].err)
+	}
+	fmt.Println("Generated", len(tracks), "track pages in", filepath.Join(*outFlag, "tracks"), "-", len(catalog.Tracks)-len(tracks), "are up to date")
+	return nil
+}
+
+// writeArtistPages generates a page per artist under web/artists/ listing
+// their tracks, for the artists whose tracks or plays changed.
+func writeArtistPages(build *siteBuild, catalog *nova.Catalog) error {
+	for _, tree := range siteTrees {
+		if err := os.MkdirAll(filepath.Join(tree.dir, "artists"), 0755); err != nil {
+			return err
+		}
+	}
+
+	var artists []*nova.CatalogArtist
+	for _, artist := range catalog.SortedArtists() {
+		if build.stale(path.Join("artists", artist.Slug+".html"), append(build.artistInputs(artist), heatmapHash(artist.Heatmap))) {
+			artists = append(artists, artist)
+		}
+	}
+	failed := runRenderJobs(len(artists)*len(siteTrees), func(i int) (string, error) {
+		artist, tree := artists[i/len(siteTrees)], siteTrees[i%len(siteTrees)]
+		output := path.Join("artists", artist.Slug+".html")
+		filename := filepath.Join(tree.dir, filepath.FromSlash(output))
+		data, err := tree.renderer.Render("artist.html", artist)
+		if err != nil {
+			return output, fmt.Errorf("failed to render %s - %w", filename, err)
+		}
+		return output, writeSiteFile(filename, data)
+	})
+	for _, page := range failed {
+		build.discard(page.output)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d artist pages failed, the first one: %w", len(failed), failed[0].err)
+	}
+	fmt.Println("Generated", len(artists), "artist pages in", filepath.Join(*outFlag, "artists"), "-", len(catalog.Artists)-len(artists), "are up to date")
+	return nil
+}
+
+// loadPlayHeatmaps counts the plays of the daily playlists by day of the
+// week and hour for the heatmaps of the pages, the catalog of the monthly
+// charts telling which tracks are new.
+func loadPlayHeatmaps(catalog *nova.Catalog) (*nova.PlayHeatmaps, error) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		return nil, err
+	}
+	plays, err := nova.LoadPlays(nova.Playlists, &nova.PlayFilter{}, paris)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the daily playlists - %w", err)
+	}
+	fmt.Println("Loaded", len(plays), "plays from the daily playlists for the heatmaps")
+	return nova.NewPlayHeatmaps(plays, catalog), nil
+}
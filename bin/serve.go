@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/mattetti/nova-playlist"
+)
+
+// runServe implements the serve command: it generates the site, serves it
+// and generates it again when the playlists in the data directory change.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	poll := fs.Duration("poll", 10*time.Second, "how often the data directory is checked for changes")
+	nowPlaying := fs.Duration("now-playing", time.Minute, "how often the playlist of the day is polled for new plays, 0 to disable")
+	// the site is generated with the same options as the main command
+	flag.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s serve:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	setupGeneration()
+	if _, err := nova.LoadYTMusicCache(); err != nil {
+		log.Fatal(fmt.Errorf("Failed to load the YT music cache - %w", err))
+	}
+
+	server := newSiteServer(*outFlag)
+	server.regenerate()
+	go server.watchData(*poll)
+	if *nowPlaying > 0 {
+		paris, err := time.LoadLocation("Europe/Paris")
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.nowPlaying = nova.NewNowPlaying(*nowPlaying, paris)
+		go server.nowPlaying.Run(context.Background())
+	}
+
+	fmt.Printf("Serving %s on http://%s\n", *outFlag, *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
+
+// siteServer serves the generated site. The pages aren't served while the
+// site is being generated so they are always consistent with each other.
+type siteServer struct {
+	dir string
+	mux *http.ServeMux
+	// mu is held for writing while the site is generated.
+	mu sync.RWMutex
+	// dataState is the state of the data directory the site was generated from.
+	dataState map[string]string
+	// checkMu serializes the checks of the data directory, lastCheck is the last one.
+	checkMu   sync.Mutex
+	lastCheck time.Time
+
+	etagsMu sync.Mutex
+	etags   map[string]cachedETag
+
+	// nowPlaying records the plays of the day, nil when it's disabled.
+	nowPlaying *nova.NowPlaying
+}
+
+type cachedETag struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+func newSiteServer(dir string) *siteServer {
+	s := &siteServer{dir: dir, mux: http.NewServeMux(), etags: make(map[string]cachedETag)}
+	s.mux.HandleFunc("/", s.serveFile)
+	s.mux.HandleFunc("/"+filepath.ToSlash(apiDir)+"/now-playing", s.serveNowPlaying)
+	return s
+}
+
+func (s *siteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// regenerate generates the outputs of the site whose inputs changed.
+func (s *siteServer) regenerate() {
+	state, err := dataDirState()
+	if err != nil {
+		log.Println("Error reading the data directory:", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := generateSite(); err != nil {
+		log.Println("Error generating the site:", err)
+	}
+	// checkpoints only rotate the backups the first time, serve keeps the
+	// ones made before it started however often the site is generated
+	if err := nova.YTMusic.Checkpoint(); err != nil {
+		log.Println("Error saving the YT Music cache:", err)
+	}
+	s.dataState = state
+}
+
+// watchData polls the data directory and generates the site again when it changes.
+func (s *siteServer) watchData(interval time.Duration) {
+	for range time.Tick(interval) {
+		s.refresh(0)
+	}
+}
+
+// refresh generates the site again if the data changed, unless the data
+// directory was checked less than minInterval ago.
+func (s *siteServer) refresh(minInterval time.Duration) {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+	if time.Since(s.lastCheck) < minInterval {
+		return
+	}
+	s.lastCheck = time.Now()
+
+	state, err := dataDirState()
+	if err != nil {
+		log.Println("Error reading the data directory:", err)
+		return
+	}
+	s.mu.RLock()
+	changed := !sameDataState(state, s.dataState)
+	s.mu.RUnlock()
+	if changed {
+		fmt.Println("The data changed, generating the site")
+		s.regenerate()
+	}
+}
+
+// dataDirState lists the size and modification time of the playlists in the
+// data directory, or the data version of the database with -db.
+func dataDirState() (map[string]string, error) {
+	state := make(map[string]string)
+	if store, ok := nova.Playlists.(*nova.SQLStore); ok {
+		version, err := store.DataVersion()
+		if err != nil {
+			return nil, fmt.Errorf("failed to check the database for changes - %w", err)
+		}
+		state[*dbFlag] = version
+		return state, nil
+	}
+	err := filepath.WalkDir(nova.PlaylistDataPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isPlaylistFile(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		state[path] = fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return state, err
+}
+
+func sameDataState(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, v := range a {
+		if b[path] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// serveFile serves a file of the site with an ETag so the clients can revalidate
+// their copy with If-None-Match as well as If-Modified-Since.
+func (s *siteServer) serveFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// the pages and the API are generated on demand when the data changed
+	// since the last check, the assets don't depend on it
+	if ext := path.Ext(r.URL.Path); ext == "" || ext == ".html" || ext == ".json" {
+		s.refresh(time.Second)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	filename := filepath.Join(s.dir, filepath.FromSlash(name))
+	f, err := os.Open(filename)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if info.IsDir() {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	etag, err := s.etag(filename, info, f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	// the site changes when the data does, the clients need to revalidate
+	w.Header().Set("Cache-Control", "no-cache")
+	if strings.HasPrefix(name, "/"+apiDir+"/") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	}
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// etag returns the ETag of a file, a hash of its content cached until the file changes.
+func (s *siteServer) etag(filename string, info os.FileInfo, f io.ReadSeeker) (string, error) {
+	s.etagsMu.Lock()
+	cached, ok := s.etags[filename]
+	s.etagsMu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.etag, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+	s.etagsMu.Lock()
+	s.etags[filename] = cachedETag{modTime: info.ModTime(), size: info.Size(), etag: etag}
+	s.etagsMu.Unlock()
+	return etag, nil
+}
+
+// serveNowPlaying streams the plays as they happen as server-sent events,
+// starting with the most recent ones.
+func (s *siteServer) serveNowPlaying(w http.ResponseWriter, r *http.Request) {
+	if s.nowPlaying == nil {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	recent, plays, unsubscribe := s.nowPlaying.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	for _, play := range recent {
+		if err := writePlayEvent(w, play); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	// comments keep the connection open through the proxies
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case play := <-plays:
+			if err := writePlayEvent(w, play); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writePlayEvent(w io.Writer, play *nova.Play) error {
+	data, err := json.Marshal(play.JSON())
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: play\nid: %s\ndata: %s\n\n", play.PlayedAt.Format(time.RFC3339), data)
+	return err
+}
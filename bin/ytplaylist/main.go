@@ -381,9 +381,11 @@ func (pc *PlaylistCreator) CreatePlaylist(novaPlaylist *nova.Playlist) (string,
 // sums each track's play count, sorts tracks by total plays, selects the top 100,
 // and creates or updates a YouTube playlist.
 func createYearlyPlaylist(creator *PlaylistCreator, year int) (string, error) {
-	files, err := os.ReadDir(nova.PlaylistDataPath)
+	keys, err := nova.Playlists.List(func(k nova.PlaylistKey) bool {
+		return k.Monthly() && k.Year == year
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to read playlists directory: %v", err)
+		return "", fmt.Errorf("failed to list the playlists: %v", err)
 	}
 
 	type TrackInfo struct {
@@ -392,21 +394,13 @@ func createYearlyPlaylist(creator *PlaylistCreator, year int) (string, error) {
 	}
 	trackMap := make(map[string]*TrackInfo)
 
-	yearStr := fmt.Sprintf("-%d.gob", year)
-	for _, file := range files {
-		if !strings.HasPrefix(file.Name(), "playlist-") || !strings.HasSuffix(file.Name(), ".gob") {
-			continue
-		}
-		// Check if the file name contains the year string (e.g. "-2024.gob")
-		if !strings.Contains(file.Name(), yearStr) {
-			continue
-		}
-		playlist, err := nova.LoadPlaylistFromFile(filepath.Join(nova.PlaylistDataPath, file.Name()))
+	for _, key := range keys {
+		playlist, err := nova.Playlists.Load(key)
 		if err != nil {
-			log.Printf("Warning: Could not load playlist %s: %v\n", file.Name(), err)
+			log.Printf("Warning: Could not load playlist %s: %v\n", key, err)
 			continue
 		}
-		log.Printf("Processing file %s (playlist.Year=%d)", file.Name(), playlist.Year)
+		log.Printf("Processing playlist %s (playlist.Year=%d)", key, playlist.Year)
 		for _, track := range playlist.Tracks {
 			key := fmt.Sprintf("%s-%s", track.Artist, track.Title)
 			if info, exists := trackMap[key]; exists {
@@ -451,43 +445,32 @@ func createYearlyPlaylist(creator *PlaylistCreator, year int) (string, error) {
 
 // loadNovaPlaylist loads a monthly playlist file for the given year and month.
 func loadNovaPlaylist(year, month int) (*nova.Playlist, error) {
-	filename := fmt.Sprintf("playlist-%s-%d.gob", nova.MonthEnglishName(time.Month(month)), year)
-	filepath := filepath.Join(nova.PlaylistDataPath, filename)
-	playlist, err := nova.LoadPlaylistFromFile(filepath)
+	key := nova.PlaylistKey{Year: year, Month: month}
+	playlist, err := nova.Playlists.Load(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load playlist %s: %v", filepath, err)
+		return nil, fmt.Errorf("failed to load playlist %s: %v", key, err)
 	}
 	return playlist, nil
 }
 
 // loadAllPlaylists loads all monthly playlists from the data directory.
 func loadAllPlaylists() ([]*nova.Playlist, error) {
-	files, err := os.ReadDir(nova.PlaylistDataPath)
+	keys, err := nova.Playlists.List(nova.MonthlyPlaylists)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read playlists directory: %v", err)
+		return nil, fmt.Errorf("failed to list the playlists: %v", err)
 	}
 
+	// the keys are sorted by date
 	var playlists []*nova.Playlist
-	for _, file := range files {
-		if file.IsDir() || !strings.HasPrefix(file.Name(), "playlist-") || !strings.HasSuffix(file.Name(), ".gob") {
-			continue
-		}
-
-		playlist, err := nova.LoadPlaylistFromFile(filepath.Join(nova.PlaylistDataPath, file.Name()))
+	for _, key := range keys {
+		playlist, err := nova.Playlists.Load(key)
 		if err != nil {
-			log.Printf("Warning: Could not load playlist %s: %v\n", file.Name(), err)
+			log.Printf("Warning: Could not load playlist %s: %v\n", key, err)
 			continue
 		}
 		playlists = append(playlists, playlist)
 	}
 
-	sort.Slice(playlists, func(i, j int) bool {
-		if playlists[i].Year == playlists[j].Year {
-			return playlists[i].Month < playlists[j].Month
-		}
-		return playlists[i].Year < playlists[j].Year
-	})
-
 	return playlists, nil
 }
 
@@ -0,0 +1,150 @@
+package nova
+
+import "sort"
+
+// Catalog indexes the tracks and artists of a set of charts,
+// e.g. to find all the charts a track was in.
+type Catalog struct {
+	// Tracks by Track.ID
+	Tracks map[string]*CatalogTrack
+	// Artists by Track.ArtistSlug
+	Artists map[string]*CatalogArtist
+}
+
+// CatalogTrack is a track and its history across the charts.
+type CatalogTrack struct {
+	ID string
+	// Track has the most recent data known about the track (YT Music match, artwork...).
+	// Its Count is the total number of plays across the charts.
+	Track *Track
+	// Appearances are sorted chronologically.
+	Appearances []ChartAppearance
+	// Heatmap has the plays of the track in the daily playlists, nil when it has none.
+	Heatmap *Heatmap
+}
+
+// ChartAppearance is the position of a track in a chart.
+type ChartAppearance struct {
+	Playlist *Playlist
+	// Rank starts at 1.
+	Rank  int
+	Count int
+}
+
+// CatalogArtist is an artist and the tracks they were played with.
+type CatalogArtist struct {
+	Slug       string
+	Name       string
+	TotalCount int
+	// Tracks are sorted by total play count.
+	Tracks []*CatalogTrack
+	// Heatmap has the plays of the artist in the daily playlists, nil when they have none.
+	Heatmap *Heatmap
+}
+
+// NewCatalog indexes the playlists, which must be sorted chronologically
+// and not overlap (e.g. monthly playlists) so the play counts add up.
+func NewCatalog(playlists []*Playlist) *Catalog {
+	c := &Catalog{
+		Tracks:  make(map[string]*CatalogTrack),
+		Artists: make(map[string]*CatalogArtist),
+	}
+
+	for _, playlist := range playlists {
+		for i, track := range playlist.Tracks {
+			id := track.ID()
+			entry, ok := c.Tracks[id]
+			if !ok {
+				entry = &CatalogTrack{ID: id, Track: &Track{}}
+				c.Tracks[id] = entry
+
+				slug := track.ArtistSlug()
+				artist, ok := c.Artists[slug]
+				if !ok {
+					artist = &CatalogArtist{Slug: slug, Name: track.Artist}
+					c.Artists[slug] = artist
+				}
+				artist.Tracks = append(artist.Tracks, entry)
+			}
+			entry.merge(track)
+			entry.Appearances = append(entry.Appearances, ChartAppearance{Playlist: playlist, Rank: i + 1, Count: track.Count})
+			c.Artists[track.ArtistSlug()].TotalCount += track.Count
+		}
+	}
+
+	for _, artist := range c.Artists {
+		sortCatalogTracks(artist.Tracks)
+	}
+	return c
+}
+
+// merge updates the track data with a more recent version of it,
+// keeping what the more recent version doesn't know about.
+func (t *CatalogTrack) merge(track *Track) {
+	total := t.Track.Count + track.Count
+	previous := t.Track
+	updated := *track
+	if updated.YTMusicInfo == nil {
+		updated.YTMusicInfo = previous.YTMusicInfo
+	}
+	if updated.YTArtistID == "" {
+		updated.YTArtistID = previous.YTArtistID
+	}
+	if updated.SpotifyURL == "" {
+		updated.SpotifyURL = previous.SpotifyURL
+	}
+	if updated.ImgURL == "" {
+		updated.ImgURL = previous.ImgURL
+	}
+	updated.Count = total
+	t.Track = &updated
+}
+
+// FirstAppearance returns the first chart the track was in.
+func (t *CatalogTrack) FirstAppearance() ChartAppearance {
+	return t.Appearances[0]
+}
+
+// SetHeatmaps sets the heatmaps of the tracks and artists of the catalog.
+func (c *Catalog) SetHeatmaps(h *PlayHeatmaps) {
+	for id, track := range c.Tracks {
+		track.Heatmap = h.Tracks[id]
+	}
+	for slug, artist := range c.Artists {
+		artist.Heatmap = h.Artists[slug]
+	}
+}
+
+// SortedTracks returns the tracks sorted by total play count.
+func (c *Catalog) SortedTracks() []*CatalogTrack {
+	tracks := make([]*CatalogTrack, 0, len(c.Tracks))
+	for _, track := range c.Tracks {
+		tracks = append(tracks, track)
+	}
+	sortCatalogTracks(tracks)
+	return tracks
+}
+
+// SortedArtists returns the artists sorted by total play count.
+func (c *Catalog) SortedArtists() []*CatalogArtist {
+	artists := make([]*CatalogArtist, 0, len(c.Artists))
+	for _, artist := range c.Artists {
+		artists = append(artists, artist)
+	}
+	sort.Slice(artists, func(i, j int) bool {
+		if artists[i].TotalCount == artists[j].TotalCount {
+			return artists[i].Slug < artists[j].Slug
+		}
+		return artists[i].TotalCount > artists[j].TotalCount
+	})
+	return artists
+}
+
+func sortCatalogTracks(tracks []*CatalogTrack) {
+	sort.Slice(tracks, func(i, j int) bool {
+		if tracks[i].Track.Count == tracks[j].Track.Count {
+			return tracks[i].ID < tracks[j].ID
+		}
+		return tracks[i].Track.Count > tracks[j].Track.Count
+	})
+}
@@ -0,0 +1,85 @@
+package nova
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// DayReport describes the daily playlist of a day in the data directory.
+type DayReport struct {
+	Date time.Time
+	// Plays is the number of tracks of the daily playlist, -1 when it's missing.
+	Plays int
+	// CachedPages is the number of pages of the day in the HTTP cache.
+	CachedPages int
+	// Short is set when the day has far fewer plays than the median day.
+	Short bool
+}
+
+// Missing reports if the daily playlist of the day isn't in the data directory.
+func (d *DayReport) Missing() bool {
+	return d.Plays < 0
+}
+
+// ScanDays reports on the daily playlists from start to end included. The days
+// with fewer plays than shortRatio times the median of the range are marked as
+// short, the median is also returned.
+func ScanDays(start, end time.Time, shortRatio float64) ([]*DayReport, int, error) {
+	var reports []*DayReport
+	var counts []int
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		report := &DayReport{Date: date, Plays: -1, CachedPages: httpCache.CachedPages(date)}
+		playlist, err := Playlists.Load(PlaylistKey{Year: date.Year(), Month: int(date.Month()), Day: date.Day()})
+		switch {
+		case err == nil:
+			report.Plays = len(playlist.Tracks)
+			counts = append(counts, report.Plays)
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, 0, fmt.Errorf("failed to load the playlist of %s - %w", date.Format("2006-01-02"), err)
+		}
+		reports = append(reports, report)
+	}
+
+	median := medianOf(counts)
+	for _, report := range reports {
+		report.Short = !report.Missing() && float64(report.Plays) < shortRatio*float64(median)
+	}
+	return reports, median, nil
+}
+
+// Gaps returns the missing and short days of the reports.
+func Gaps(reports []*DayReport) []*DayReport {
+	var gaps []*DayReport
+	for _, report := range reports {
+		if report.Missing() || report.Short {
+			gaps = append(gaps, report)
+		}
+	}
+	return gaps
+}
+
+// RemoveDailyPlaylist removes the daily playlist of date from the data directory
+// so GetPlaylist fetches it again. With cachedPages, the pages of the day are
+// also removed from the HTTP cache so they are fetched again too.
+func RemoveDailyPlaylist(date time.Time, cachedPages bool) error {
+	key := PlaylistKey{Year: date.Year(), Month: int(date.Month()), Day: date.Day()}
+	if err := Playlists.Delete(key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if cachedPages {
+		return httpCache.RemovePages(date)
+	}
+	return nil
+}
+
+func medianOf(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
@@ -6,10 +6,20 @@ require (
 	github.com/PuerkitoBio/goquery v1.9.3
 	github.com/mattetti/goRailsYourself v1.0.0
 	github.com/raitonoberu/ytmusic v0.0.0-20240324143733-0e5780514b1d
+	modernc.org/sqlite v1.34.5
 )
 
 require (
 	github.com/andybalholm/cascadia v1.3.3 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/fiam/gounidecode v0.0.0-20150629112515-8deddbd03fec // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	golang.org/x/net v0.35.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
 )
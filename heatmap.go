@@ -0,0 +1,136 @@
+package nova
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// Heatmap counts plays by day of the week and hour of the day.
+type Heatmap [7][24]int
+
+// Add counts a play at t.
+func (h *Heatmap) Add(t time.Time) {
+	h[t.Weekday()][t.Hour()]++
+}
+
+// Total is the number of plays counted.
+func (h *Heatmap) Total() int {
+	total := 0
+	for _, hours := range h {
+		for _, n := range hours {
+			total += n
+		}
+	}
+	return total
+}
+
+// Max is the largest number of plays of a cell.
+func (h *Heatmap) Max() int {
+	max := 0
+	for _, hours := range h {
+		for _, n := range hours {
+			if n > max {
+				max = n
+			}
+		}
+	}
+	return max
+}
+
+// PlayHeatmaps are the heatmaps of the plays of the daily playlists.
+type PlayHeatmaps struct {
+	// Tracks by Track.ID.
+	Tracks map[string]*Heatmap
+	// Artists by Track.ArtistSlug.
+	Artists map[string]*Heatmap
+	// Introductions counts the first play of each track which wasn't in a
+	// monthly chart before.
+	Introductions *Heatmap
+}
+
+// NewPlayHeatmaps counts the plays, which must be sorted chronologically
+// (see LoadPlays). The daily playlists start after the monthly charts of the
+// catalog, the tracks charted before the month of their first daily play were
+// already in rotation and aren't introductions.
+func NewPlayHeatmaps(plays []*Play, catalog *Catalog) *PlayHeatmaps {
+	h := &PlayHeatmaps{
+		Tracks:        make(map[string]*Heatmap),
+		Artists:       make(map[string]*Heatmap),
+		Introductions: &Heatmap{},
+	}
+	for _, play := range plays {
+		id := play.Track.ID()
+		track, ok := h.Tracks[id]
+		if !ok {
+			track = &Heatmap{}
+			h.Tracks[id] = track
+			if !catalog.chartedBefore(play) {
+				h.Introductions.Add(play.PlayedAt)
+			}
+		}
+		track.Add(play.PlayedAt)
+
+		slug := play.Track.ArtistSlug()
+		if h.Artists[slug] == nil {
+			h.Artists[slug] = &Heatmap{}
+		}
+		h.Artists[slug].Add(play.PlayedAt)
+	}
+	return h
+}
+
+// chartedBefore reports if the track of the play was in a chart of the
+// catalog before the month of the play.
+func (c *Catalog) chartedBefore(p *Play) bool {
+	if c == nil {
+		return false
+	}
+	track, ok := c.Tracks[p.Track.ID()]
+	if !ok || len(track.Appearances) == 0 {
+		return false
+	}
+	first := track.FirstAppearance().Playlist
+	return first.Year*12+first.Month < p.PlayedAt.Year()*12+int(p.PlayedAt.Month())
+}
+
+const (
+	heatmapCell   = 14
+	heatmapGap    = 2
+	heatmapLabelW = 36
+	heatmapLabelH = 16
+)
+
+// SVG renders the heatmap with a row per day from Monday and a column per
+// hour, the more opaque the cell the more plays. The cells have their count
+// as a tooltip, formatted with the plural message unit (e.g. "plays").
+func (h *Heatmap) SVG(locale *Locale, unit string) template.HTML {
+	step := heatmapCell + heatmapGap
+	width := heatmapLabelW + 24*step
+	height := heatmapLabelH + 7*step
+	max := h.Max()
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg class="heatmap" xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" role="img">`, width, height, width, height)
+	for hour := 0; hour < 24; hour += 3 {
+		fmt.Fprintf(&svg, `<text class="heatmap-hour" x="%d" y="%d">%d</text>`, heatmapLabelW+hour*step, heatmapLabelH-4, hour)
+	}
+	for row := 0; row < 7; row++ {
+		day := time.Weekday((row + 1) % 7)
+		y := heatmapLabelH + row*step
+		fmt.Fprintf(&svg, `<text class="heatmap-day" x="0" y="%d">%s</text>`, y+heatmapCell-3, template.HTMLEscapeString(locale.Weekdays[day]))
+		for hour := 0; hour < 24; hour++ {
+			n := h[day][hour]
+			fill := `class="empty"`
+			if n > 0 {
+				fill = fmt.Sprintf(`fill-opacity="%.2f"`, 0.15+0.85*float64(n)/float64(max))
+			}
+			title := locale.T("heatmap.cell", locale.Weekdays[day], hour, locale.Plural(unit, n))
+			fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" %s><title>%s</title></rect>`,
+				heatmapLabelW+hour*step, y, heatmapCell, heatmapCell, fill, template.HTMLEscapeString(title))
+		}
+	}
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
@@ -18,21 +18,71 @@ type HTTPCache struct {
 	mutex sync.Mutex
 }
 
-func (c *HTTPCache) GetPlaylistPage(date time.Time, page int, nonce string) ([]byte, bool, error) {
+// dayDir is the directory of the cached pages of the playlist of a day.
+func (c *HTTPCache) dayDir(date time.Time) string {
+	return fmt.Sprintf("%s/%d/%02d/%02d", c.dir, date.Year(), date.Month(), date.Day())
+}
+
+// CachedPages returns the number of pages of the playlist of a day in the cache.
+func (c *HTTPCache) CachedPages(date time.Time) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	pages, _ := filepath.Glob(filepath.Join(c.dayDir(date), "playlist-page-*.html"))
+	return len(pages)
+}
+
+// RemovePages removes the pages of the playlist of a day from the cache.
+func (c *HTTPCache) RemovePages(date time.Time) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	return os.RemoveAll(c.dayDir(date))
+}
 
+// GetPlaylistPage returns a page of the playlist of a day from the cache,
+// fetching and caching it when it's missing. The cache isn't locked while
+// the page is fetched, which can take minutes with the retries.
+func (c *HTTPCache) GetPlaylistPage(date time.Time, page int, nonce string) ([]byte, bool, error) {
 	dDate := fmt.Sprintf("%04d-%02d-%02d", date.Year(), date.Month(), date.Day())
 
-	cacheFilePath := fmt.Sprintf("%s/%d/%02d/%02d/playlist-page-%s-%d.html", c.dir, date.Year(), date.Month(), date.Day(), dDate, page)
+	cacheFilePath := fmt.Sprintf("%s/playlist-page-%s-%d.html", c.dayDir(date), dDate, page)
 
+	c.mutex.Lock()
 	if FileExists(cacheFilePath) {
 		body, err := ioutil.ReadFile(cacheFilePath)
 		if err == nil {
+			c.mutex.Unlock()
 			fmt.Println("x")
 			return body, true, nil
 		}
 	}
+	c.mutex.Unlock()
+
+	ioBody, err := fetchPlaylistPage(date, page, nonce)
+	// protect against empty responses
+	if err == nil && len(ioBody) > 12 {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		dir := filepath.Dir(cacheFilePath)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			_ = os.MkdirAll(dir, 0700)
+		}
+		err = ioutil.WriteFile(cacheFilePath, ioBody, 0644)
+		if err != nil {
+			fmt.Println("Error writing the playlist to cache:", err)
+		}
+	}
+	return ioBody, false, err
+}
+
+// GetFreshPlaylistPage is like GetPlaylistPage but bypasses the cache, the page
+// isn't cached either since the pages of the current day keep changing.
+func (c *HTTPCache) GetFreshPlaylistPage(date time.Time, page int, nonce string) ([]byte, error) {
+	return fetchPlaylistPage(date, page, nonce)
+}
+
+// fetchPlaylistPage gets a page of the playlist of a day from nova.fr.
+func fetchPlaylistPage(date time.Time, page int, nonce string) ([]byte, error) {
+	dDate := fmt.Sprintf("%04d-%02d-%02d", date.Year(), date.Month(), date.Day())
 
 	payload := "action=loadmore_programs"
 	payload += "&afp_nonce=" + nonce
@@ -68,10 +118,7 @@ func (c *HTTPCache) GetPlaylistPage(date time.Time, page int, nonce string) ([]b
 	for _, backoff := range backoffSchedule {
 		resp, err = client.Do(req)
 		if err != nil {
-			fmt.Println("Error getting the playlist from nova.fr, payload", payload)
-			// print the response's body
-			body, _ := ioutil.ReadAll(resp.Body)
-			fmt.Println(string(body))
+			fmt.Println("Error getting the playlist from nova.fr, payload", payload, "-", err)
 			fmt.Println("Waiting", backoff, "before retrying")
 			time.Sleep(backoff)
 			continue
@@ -95,22 +142,13 @@ func (c *HTTPCache) GetPlaylistPage(date time.Time, page int, nonce string) ([]b
 		break
 	}
 
-	if (resp == nil) || (resp.StatusCode != 200) {
+	if resp == nil {
 		log.Printf("failed to retrieve playlist for %s, page %d\n", dDate, page)
-		return nil, false, fmt.Errorf("failed to retrieve playlist for %s, page %d - status code: %d", dDate, page, resp.StatusCode)
+		return nil, fmt.Errorf("failed to retrieve playlist for %s, page %d - %w", dDate, page, err)
 	}
-	ioBody, err := ioutil.ReadAll(resp.Body)
-	// protect against empty responses
-	if err == nil && len(ioBody) > 12 {
-		dir := filepath.Dir(cacheFilePath)
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			_ = os.MkdirAll(dir, 0700)
-		}
-		err = ioutil.WriteFile(cacheFilePath, ioBody, 0644)
-		if err != nil {
-			fmt.Println("Error writing the playlist to cache:", err)
-		}
+	if resp.StatusCode != 200 {
+		log.Printf("failed to retrieve playlist for %s, page %d\n", dDate, page)
+		return nil, fmt.Errorf("failed to retrieve playlist for %s, page %d - status code: %d", dDate, page, resp.StatusCode)
 	}
-	return ioBody, false, err
-
+	return ioutil.ReadAll(resp.Body)
 }
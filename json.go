@@ -0,0 +1,190 @@
+package nova
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PlaylistJSONVersion is the version of the JSON export format,
+// bumped on breaking changes to PlaylistJSON or TrackJSON.
+const PlaylistJSONVersion = 1
+
+// PlaylistJSONSchema is the JSON Schema describing the ToJSON output.
+//
+//go:embed schema/playlist.schema.json
+var PlaylistJSONSchema []byte
+
+// PlaylistJSON is the JSON representation of a playlist, see schema/playlist.schema.json.
+type PlaylistJSON struct {
+	Version  int         `json:"version"`
+	Name     string      `json:"name"`
+	Title    string      `json:"title"`
+	Year     int         `json:"year,omitempty"`
+	Month    int         `json:"month,omitempty"`
+	Day      int         `json:"day,omitempty"`
+	Previous string      `json:"previous,omitempty"`
+	Next     string      `json:"next,omitempty"`
+	Tracks   []TrackJSON `json:"tracks"`
+}
+
+// TrackJSON is the JSON representation of a ranked track.
+type TrackJSON struct {
+	// ID is the track ID used by the API, see Track.ID.
+	ID         string `json:"id"`
+	ArtistSlug string `json:"artistSlug"`
+	Rank       int    `json:"rank"`
+	// PreviousRank is nil when the track wasn't in the previous playlist.
+	PreviousRank    *int              `json:"previousRank"`
+	Count           int               `json:"count"`
+	Artist          string            `json:"artist"`
+	Title           string            `json:"title"`
+	DurationSeconds int               `json:"durationSeconds,omitempty"`
+	ThumbnailURL    string            `json:"thumbnailUrl,omitempty"`
+	YTMusic         *YTMusicJSON      `json:"youtubeMusic,omitempty"`
+	Spotify         *SpotifyTrackJSON `json:"spotify,omitempty"`
+}
+
+type YTMusicJSON struct {
+	VideoID   string `json:"videoId"`
+	URL       string `json:"url"`
+	ArtistID  string `json:"artistId,omitempty"`
+	ArtistURL string `json:"artistUrl,omitempty"`
+	AlbumID   string `json:"albumId,omitempty"`
+	Album     string `json:"album,omitempty"`
+}
+
+type SpotifyTrackJSON struct {
+	// ID is only set for open.spotify.com links, Nova sometimes links to other services.
+	ID  string `json:"id,omitempty"`
+	URL string `json:"url"`
+}
+
+// JSON returns the playlist in its JSON representation.
+func (p *Playlist) JSON() *PlaylistJSON {
+	doc := &PlaylistJSON{
+		Version: PlaylistJSONVersion,
+		Name:    p.Name,
+		Title:   p.Title(),
+		Year:    p.Year,
+		Month:   p.Month,
+		Day:     p.Day,
+		Tracks:  make([]TrackJSON, 0, len(p.Tracks)),
+	}
+	if p.PreviousPlaylist != nil {
+		doc.Previous = p.PreviousPlaylist.Name
+	}
+	if p.NextPlaylist != nil {
+		doc.Next = p.NextPlaylist.Name
+	}
+
+	for i, track := range p.Tracks {
+		entry := track.JSON()
+		entry.Rank = i + 1
+		if previous := p.PreviousRanking(track); previous > -1 {
+			rank := previous + 1
+			entry.PreviousRank = &rank
+		}
+		doc.Tracks = append(doc.Tracks, entry)
+	}
+	return doc
+}
+
+// JSON returns the JSON representation of the track, without its ranks.
+func (t *Track) JSON() TrackJSON {
+	entry := TrackJSON{
+		ID:              t.ID(),
+		ArtistSlug:      t.ArtistSlug(),
+		Count:           t.Count,
+		Artist:          t.Artist,
+		Title:           t.Title,
+		DurationSeconds: t.DurationSeconds(),
+		ThumbnailURL:    t.ThumbURL(),
+	}
+	if info := t.YTMusicInfo; info != nil && info.VideoID != "" {
+		entry.YTMusic = &YTMusicJSON{
+			VideoID:  info.VideoID,
+			URL:      t.YTMusicURL(),
+			ArtistID: t.YTArtistBrowseID(),
+			AlbumID:  info.Album.ID,
+			Album:    info.Album.Name,
+		}
+		if entry.YTMusic.ArtistID != "" {
+			entry.YTMusic.ArtistURL = t.YTPrimaryArtistURL()
+		}
+	}
+	if t.SpotifyURL != "" {
+		entry.Spotify = &SpotifyTrackJSON{ID: spotifyTrackID(t.SpotifyURL), URL: t.SpotifyURL}
+	}
+	return entry
+}
+
+// ToJSON returns the playlist encoded as JSON, see PlaylistJSONSchema.
+func (p *Playlist) ToJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(p.JSON(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the playlist as JSON %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// csvHeader lists the columns of the CSV export.
+var csvHeader = []string{
+	"rank", "previous_rank", "count", "artist", "title", "duration_seconds", "thumbnail_url",
+	"ytmusic_video_id", "ytmusic_url", "ytmusic_artist_id", "ytmusic_artist_url", "ytmusic_album_id", "ytmusic_album",
+	"spotify_id", "spotify_url", "id", "artist_slug",
+}
+
+// ToCSV returns the playlist as CSV, one row per track with the same
+// fields as the JSON export.
+func (p *Playlist) ToCSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(csvHeader)
+
+	for _, track := range p.JSON().Tracks {
+		var previousRank, duration string
+		if track.PreviousRank != nil {
+			previousRank = strconv.Itoa(*track.PreviousRank)
+		}
+		if track.DurationSeconds > 0 {
+			duration = strconv.Itoa(track.DurationSeconds)
+		}
+		yt := track.YTMusic
+		if yt == nil {
+			yt = &YTMusicJSON{}
+		}
+		spotify := track.Spotify
+		if spotify == nil {
+			spotify = &SpotifyTrackJSON{}
+		}
+		w.Write([]string{
+			strconv.Itoa(track.Rank), previousRank, strconv.Itoa(track.Count), track.Artist, track.Title, duration, track.ThumbnailURL,
+			yt.VideoID, yt.URL, yt.ArtistID, yt.ArtistURL, yt.AlbumID, yt.Album,
+			spotify.ID, spotify.URL, track.ID, track.ArtistSlug,
+		})
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to encode the playlist as CSV %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// spotifyTrackID extracts the track ID from an open.spotify.com track URL.
+func spotifyTrackID(url string) string {
+	const prefix = "https://open.spotify.com/track/"
+	if !strings.HasPrefix(url, prefix) {
+		return ""
+	}
+	id := strings.TrimPrefix(url, prefix)
+	if i := strings.IndexAny(id, "?/#"); i >= 0 {
+		id = id[:i]
+	}
+	return id
+}
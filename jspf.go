@@ -0,0 +1,201 @@
+package nova
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/raitonoberu/ytmusic"
+)
+
+// jspfExtension namespaces the data JSPF has no field for,
+// so a playlist can be read back without losing anything.
+const jspfExtension = "https://github.com/mattetti/nova-playlist"
+
+// jspfDocument is a JSPF playlist as consumed by ListenBrainz,
+// see https://musicbrainz.org/doc/jspf
+type jspfDocument struct {
+	Playlist jspfPlaylist `json:"playlist"`
+}
+
+type jspfPlaylist struct {
+	Title      string                       `json:"title"`
+	Creator    string                       `json:"creator,omitempty"`
+	Annotation string                       `json:"annotation,omitempty"`
+	Info       string                       `json:"info,omitempty"`
+	Date       string                       `json:"date,omitempty"`
+	Extension  map[string]jspfPlaylistExtra `json:"extension,omitempty"`
+	Tracks     []jspfTrack                  `json:"track"`
+}
+
+type jspfPlaylistExtra struct {
+	Name  string `json:"name,omitempty"`
+	Year  int    `json:"year,omitempty"`
+	Month int    `json:"month,omitempty"`
+	Day   int    `json:"day,omitempty"`
+}
+
+type jspfTrack struct {
+	Title      string                    `json:"title"`
+	Creator    string                    `json:"creator"`
+	Album      string                    `json:"album,omitempty"`
+	Annotation string                    `json:"annotation,omitempty"`
+	Image      string                    `json:"image,omitempty"`
+	Duration   int                       `json:"duration,omitempty"`
+	Location   jspfStrings               `json:"location,omitempty"`
+	Extension  map[string]jspfTrackExtra `json:"extension,omitempty"`
+}
+
+type jspfTrackExtra struct {
+	Artist      string             `json:"artist"`
+	Title       string             `json:"title"`
+	Count       int                `json:"count"`
+	Hour        int                `json:"hour"`
+	Minute      int                `json:"minute"`
+	ImgURL      string             `json:"imgUrl,omitempty"`
+	SpotifyURL  string             `json:"spotifyUrl,omitempty"`
+	YTArtistID  string             `json:"ytArtistId,omitempty"`
+	YTMusicInfo *ytmusic.TrackItem `json:"ytMusic,omitempty"`
+}
+
+// jspfStrings is a list of URIs. JSPF uses arrays but some producers
+// write a single string, both are accepted when decoding.
+type jspfStrings []string
+
+func (s *jspfStrings) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*s = jspfStrings{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*s = many
+	return nil
+}
+
+// ToJSPF returns the playlist in the JSPF format used by ListenBrainz.
+// The tracks have no MusicBrainz identifier, consumers match them by their
+// artist and title.
+func (p *Playlist) ToJSPF() ([]byte, error) {
+	doc := jspfDocument{Playlist: jspfPlaylist{
+		Title:   "Radio Nova " + p.Title(),
+		Creator: "Radio Nova",
+		Info:    "https://www.nova.fr/c-etait-quoi-ce-titre/",
+		Extension: map[string]jspfPlaylistExtra{jspfExtension: {
+			Name:  p.Name,
+			Year:  p.Year,
+			Month: p.Month,
+			Day:   p.Day,
+		}},
+		Tracks: make([]jspfTrack, 0, len(p.Tracks)),
+	}}
+	if p.Year > 0 {
+		month, day := max(p.Month, 1), max(p.Day, 1)
+		doc.Playlist.Date = time.Date(p.Year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	}
+
+	for _, track := range p.Tracks {
+		entry := jspfTrack{
+			Title:    track.Title,
+			Creator:  track.Artist,
+			Image:    track.ThumbURL(),
+			Duration: track.DurationSeconds() * 1000,
+			Extension: map[string]jspfTrackExtra{jspfExtension: {
+				Artist:      track.Artist,
+				Title:       track.Title,
+				Count:       track.Count,
+				Hour:        track.Hour,
+				Minute:      track.Minute,
+				ImgURL:      track.ImgURL,
+				SpotifyURL:  track.SpotifyURL,
+				YTArtistID:  track.YTArtistID,
+				YTMusicInfo: track.YTMusicInfo,
+			}},
+		}
+		if track.YTMusicInfo != nil {
+			entry.Album = track.YTMusicInfo.Album.Name
+		}
+		if track.Count > 0 {
+			entry.Annotation = fmt.Sprintf("%d plays", track.Count)
+		}
+		for _, location := range []string{track.YTMusicURL(), track.SpotifyURL} {
+			if location != "" {
+				entry.Location = append(entry.Location, location)
+			}
+		}
+		doc.Playlist.Tracks = append(doc.Playlist.Tracks, entry)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the JSPF playlist %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ReadJSPF decodes a JSPF playlist. Playlists exported by ToJSPF are restored
+// as they were, the ones coming from other tools only get the fields JSPF
+// has an equivalent for.
+func ReadJSPF(r io.Reader) (*Playlist, error) {
+	var doc jspfDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode the JSPF playlist %w", err)
+	}
+
+	p := &Playlist{Name: doc.Playlist.Title}
+	if extra, ok := doc.Playlist.Extension[jspfExtension]; ok {
+		p.Name = extra.Name
+		p.Year = extra.Year
+		p.Month = extra.Month
+		p.Day = extra.Day
+	} else if date, err := time.Parse(time.RFC3339, doc.Playlist.Date); err == nil {
+		p.Year = date.Year()
+		p.Month = int(date.Month())
+	}
+
+	for _, entry := range doc.Playlist.Tracks {
+		track := &Track{Artist: entry.Creator, Title: entry.Title, ImgURL: entry.Image}
+		if extra, ok := entry.Extension[jspfExtension]; ok {
+			track.Artist = extra.Artist
+			track.Title = extra.Title
+			track.Count = extra.Count
+			track.Hour = extra.Hour
+			track.Minute = extra.Minute
+			track.ImgURL = extra.ImgURL
+			track.SpotifyURL = extra.SpotifyURL
+			track.YTArtistID = extra.YTArtistID
+			track.YTMusicInfo = extra.YTMusicInfo
+		} else {
+			for _, location := range entry.Location {
+				switch {
+				case strings.HasPrefix(location, "https://music.youtube.com/watch?v="):
+					track.YTMusicInfo = &ytmusic.TrackItem{
+						VideoID:  strings.TrimPrefix(location, "https://music.youtube.com/watch?v="),
+						Title:    entry.Title,
+						Duration: entry.Duration / 1000,
+					}
+				case track.SpotifyURL == "":
+					track.SpotifyURL = location
+				}
+			}
+		}
+		p.Tracks = append(p.Tracks, track)
+	}
+	return p, nil
+}
+
+// LoadPlaylistFromJSPF reads the JSPF playlist stored at path.
+func LoadPlaylistFromJSPF(path string) (*Playlist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the file %w", err)
+	}
+	defer file.Close()
+	return ReadJSPF(file)
+}
@@ -0,0 +1,42 @@
+package nova
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestJSPFRoundTrip(t *testing.T) {
+	keys, err := Playlists.List(MonthlyPlaylists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) == 0 {
+		t.Skip("no monthly playlists in the data directory")
+	}
+	for _, key := range keys {
+		t.Run(key.String(), func(t *testing.T) {
+			want, err := Playlists.Load(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := want.ToJSPF()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadJSPF(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("the playlist read back differs from the exported one")
+				for i := range want.Tracks {
+					if i < len(got.Tracks) && !reflect.DeepEqual(got.Tracks[i], want.Tracks[i]) {
+						t.Errorf("track %d: got %+v, want %+v", i, got.Tracks[i], want.Tracks[i])
+						break
+					}
+				}
+			}
+		})
+	}
+}
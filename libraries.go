@@ -0,0 +1,142 @@
+package nova
+
+import (
+	"bufio"
+	"crypto/sha512"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Library is a third-party script loaded by the generated pages.
+type Library struct {
+	Name string
+	// URL is the pinned version of the library on its CDN.
+	URL string
+	// Integrity is the Subresource Integrity hash of the library, from libraries.sum.
+	Integrity string
+}
+
+// Libraries are the third-party scripts the pages depend on.
+var Libraries = []*Library{
+	{Name: "react", URL: "https://cdnjs.cloudflare.com/ajax/libs/react/18.2.0/umd/react.production.min.js"},
+	{Name: "react-dom", URL: "https://cdnjs.cloudflare.com/ajax/libs/react-dom/18.2.0/umd/react-dom.production.min.js"},
+}
+
+// VendorLibraries makes the pages load the libraries from the vendor directory
+// of the site (see VendorLibrariesTo) instead of their CDN.
+var VendorLibraries bool
+
+// librariesSum lists the integrity of the pinned libraries, one "<url> <integrity>"
+// per line. It's generated with `nova libraries`.
+//
+//go:embed libraries.sum
+var librariesSum string
+
+func init() {
+	scanner := bufio.NewScanner(strings.NewReader(librariesSum))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if lib := libraryByURL(fields[0]); lib != nil {
+			lib.Integrity = fields[1]
+		}
+	}
+}
+
+func libraryByURL(url string) *Library {
+	for _, lib := range Libraries {
+		if lib.URL == url {
+			return lib
+		}
+	}
+	return nil
+}
+
+func libraryByName(name string) *Library {
+	for _, lib := range Libraries {
+		if lib.Name == name {
+			return lib
+		}
+	}
+	return nil
+}
+
+// VendorPath is where the library is copied in the site.
+func (l *Library) VendorPath() string {
+	return path.Join("vendor", l.Name, path.Base(l.URL))
+}
+
+// Script is the script tag loading the library, from the vendor directory
+// of the site at root when VendorLibraries is set.
+func (l *Library) Script(root string) template.HTML {
+	if VendorLibraries {
+		return template.HTML(fmt.Sprintf(`<script src="%s"></script>`, template.HTMLEscapeString(root+l.VendorPath())))
+	}
+	if l.Integrity == "" {
+		return template.HTML(fmt.Sprintf(`<script src="%s"></script>`, template.HTMLEscapeString(l.URL)))
+	}
+	return template.HTML(fmt.Sprintf(`<script src="%s" integrity="%s" crossorigin="anonymous"></script>`,
+		template.HTMLEscapeString(l.URL), template.HTMLEscapeString(l.Integrity)))
+}
+
+// Download fetches the library from its CDN and checks its integrity when known.
+func (l *Library) Download() ([]byte, error) {
+	resp, err := client.Get(l.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s - %w", l.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s - %s", l.Name, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s - %w", l.Name, err)
+	}
+	if l.Integrity != "" && Integrity(data) != l.Integrity {
+		return nil, fmt.Errorf("%s doesn't match its integrity %s, got %s", l.URL, l.Integrity, Integrity(data))
+	}
+	return data, nil
+}
+
+// VendorLibrariesTo downloads the libraries to the vendor directory of the site in dir.
+// The libraries must have their integrity in libraries.sum to be checked.
+func VendorLibrariesTo(dir string) error {
+	for _, lib := range Libraries {
+		if lib.Integrity == "" {
+			return fmt.Errorf("%s has no integrity in libraries.sum, add it with nova libraries", lib.URL)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(lib.VendorPath()))
+		// the library is only downloaded again when it's missing or changed
+		if data, err := os.ReadFile(target); err == nil && Integrity(data) == lib.Integrity {
+			continue
+		}
+		data, err := lib.Download()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Integrity returns the Subresource Integrity hash of data.
+func Integrity(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,171 @@
+package nova
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale has the month names and the messages of the generated pages in a language.
+type Locale struct {
+	// Lang is the language code, used in the lang and hreflang attributes.
+	Lang   string
+	Months [12]string
+	// Weekdays are the short names of the days, indexed by time.Weekday.
+	Weekdays [7]string
+	// Messages are format strings, keys ending with .one and .other are the
+	// singular and plural forms used by Plural.
+	Messages map[string]string
+	// singular reports if a count takes the singular form.
+	singular func(n int) bool
+}
+
+var English = &Locale{
+	Lang:     "en",
+	Months:   [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	Weekdays: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	singular: func(n int) bool { return n == 1 },
+	Messages: map[string]string{
+		"allTimes":         "All Times",
+		"nav.all":          "All Playlists",
+		"nav.search":       "Search",
+		"playlist.title":   "Radio Nova %s - Playlist",
+		"playlist.by":      "by",
+		"ranking.up":       "Up %s",
+		"ranking.down":     "Down %s",
+		"places.one":       "%d place",
+		"places.other":     "%d places",
+		"plays.one":        "%d play",
+		"plays.other":      "%d plays",
+		"charts.one":       "%d monthly chart",
+		"charts.other":     "%d monthly charts",
+		"index.title":      "Radio Nova - Playlists",
+		"index.search":     "Search a track",
+		"index.subscribe":  "Subscribe:",
+		"index.feedCharts": "new charts",
+		"index.feedTracks": "new tracks",
+		"index.yearly":     "Yearly Playlists",
+		"index.monthly":    "Monthly Playlists",
+		"index.topTrack":   "Top track: %s by %s",
+		"track.title":      "%s by %s - Radio Nova",
+		"track.by":         "by %s",
+		"track.played":     "%s in %s",
+		"track.chart":      "Chart",
+		"track.rank":       "Rank",
+		"track.plays":      "Plays",
+		"track.heatmap":    "When it's played",
+		"tracks.one":       "%d track",
+		"tracks.other":     "%d tracks",
+		"newTracks.one":    "%d new track",
+		"newTracks.other":  "%d new tracks",
+		"artist.title":     "%s - Radio Nova",
+		"artist.played":    "%s of %s",
+		"artist.track":     "Track",
+		"artist.heatmap":   "When they're played",
+		"index.heatmap":    "When the new tracks are introduced",
+		"heatmap.cell":     "%s %dh: %s",
+	},
+}
+
+var French = &Locale{
+	Lang:     "fr",
+	Months:   [12]string{"Janvier", "Février", "Mars", "Avril", "Mai", "Juin", "Juillet", "Août", "Septembre", "Octobre", "Novembre", "Décembre"},
+	Weekdays: [7]string{"Dim", "Lun", "Mar", "Mer", "Jeu", "Ven", "Sam"},
+	singular: func(n int) bool { return n <= 1 },
+	Messages: map[string]string{
+		"allTimes":         "De tous les temps",
+		"nav.all":          "Toutes les playlists",
+		"nav.search":       "Recherche",
+		"playlist.title":   "Radio Nova %s - Playlist",
+		"playlist.by":      "par",
+		"ranking.up":       "Monte de %s",
+		"ranking.down":     "Descend de %s",
+		"places.one":       "%d place",
+		"places.other":     "%d places",
+		"plays.one":        "%d diffusion",
+		"plays.other":      "%d diffusions",
+		"charts.one":       "%d classement mensuel",
+		"charts.other":     "%d classements mensuels",
+		"index.title":      "Radio Nova - Playlists",
+		"index.search":     "Chercher un titre",
+		"index.subscribe":  "S'abonner :",
+		"index.feedCharts": "nouveaux classements",
+		"index.feedTracks": "nouveaux titres",
+		"index.yearly":     "Playlists annuelles",
+		"index.monthly":    "Playlists mensuelles",
+		"index.topTrack":   "Titre phare : %s par %s",
+		"track.title":      "%s par %s - Radio Nova",
+		"track.by":         "par %s",
+		"track.played":     "%s dans %s",
+		"track.chart":      "Classement",
+		"track.rank":       "Rang",
+		"track.plays":      "Diffusions",
+		"track.heatmap":    "Quand il passe",
+		"tracks.one":       "%d titre",
+		"tracks.other":     "%d titres",
+		"newTracks.one":    "%d nouveau titre",
+		"newTracks.other":  "%d nouveaux titres",
+		"artist.title":     "%s - Radio Nova",
+		"artist.played":    "%s de %s",
+		"artist.track":     "Titre",
+		"artist.heatmap":   "Quand ses titres passent",
+		"index.heatmap":    "Quand les nouveaux titres arrivent",
+		"heatmap.cell":     "%s %dh : %s",
+	},
+}
+
+// Locales are the languages the site is generated in.
+var Locales = []*Locale{French, English}
+
+// LocaleByLang returns the locale of a language code, nil if it isn't supported.
+func LocaleByLang(lang string) *Locale {
+	for _, l := range Locales {
+		if strings.EqualFold(l.Lang, lang) {
+			return l
+		}
+	}
+	return nil
+}
+
+// MonthName is the localized name of a month.
+func (l *Locale) MonthName(month time.Month) string {
+	if month < time.January || month > time.December {
+		return "Unknown"
+	}
+	return l.Months[month-1]
+}
+
+// T formats the message with the given key, falling back to English
+// and then to the key itself when the locale doesn't have it.
+func (l *Locale) T(key string, args ...any) string {
+	format, ok := l.Messages[key]
+	if !ok {
+		if format, ok = English.Messages[key]; !ok {
+			return key
+		}
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Plural formats the singular or plural form of the message with the given key
+// (e.g. "plays" for "plays.one" and "plays.other") for n.
+func (l *Locale) Plural(key string, n int) string {
+	if l.singular(n) {
+		return l.T(key+".one", n)
+	}
+	return l.T(key+".other", n)
+}
+
+// PlaylistTitle is the localized version of Playlist.Title.
+func (l *Locale) PlaylistTitle(p *Playlist) string {
+	switch {
+	case p == nil:
+		return ""
+	case p.Year > 0 && p.Month > 0:
+		return l.MonthName(time.Month(p.Month)) + " " + strconv.Itoa(p.Year)
+	case p.Year == 0 && p.Month == 0 && p.Day == 0:
+		return l.T("allTimes")
+	}
+	return p.Name
+}
@@ -0,0 +1,40 @@
+package nova
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ToM3U8 returns the playlist in the extended M3U format (UTF-8).
+// Tracks without a YT Music or Spotify URL are skipped since an M3U entry
+// needs a location.
+func (p *Playlist) ToM3U8() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&buf, "#PLAYLIST:Radio Nova %s\n", m3uText(p.Title()))
+
+	for _, track := range p.Tracks {
+		location := track.PlaybackURL()
+		if location == "" {
+			continue
+		}
+		// -1 is the conventional value for an unknown duration
+		duration := track.DurationSeconds()
+		if duration == 0 {
+			duration = -1
+		}
+		fmt.Fprintf(&buf, "#EXTINF:%d,%s - %s\n", duration, m3uText(track.Artist), m3uText(track.Title))
+		if thumb := track.ThumbURL(); thumb != "" {
+			fmt.Fprintf(&buf, "#EXTIMG:%s\n", thumb)
+		}
+		buf.WriteString(location + "\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// m3uText makes sure a value fits on a single M3U line.
+func m3uText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -3,10 +3,12 @@ package nova
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -81,42 +83,13 @@ func GetPlaylist(date time.Time, nonce string) *Playlist {
 			lastRequest = time.Now()
 		}
 
-		// create a bytes reader from the body
-		r := bytes.NewReader(body)
-
-		doc, err := goquery.NewDocumentFromReader(r)
+		tracks, err := ParsePlaylistPage(body)
 		if err != nil {
-			fmt.Println("Error creating goquery document:", err)
+			fmt.Println("Error parsing the playlist page:", err)
 			return nil
 		}
-
-		nbrItems = 0
-
-		doc.Find(`div.wwtt_content`).Each(func(i int, item *goquery.Selection) {
-			track := &Track{}
-			nbrItems++
-			item.Find(`div.col-lg-7 > div > h2`).Each(func(i int, s *goquery.Selection) {
-				track.Artist = strings.Join(strings.Split(strings.ToLower(s.Text()), "/"), " and ")
-			})
-
-			item.Find(`div.col-lg-7 div p:not([class])`).Each(func(i int, s *goquery.Selection) {
-				track.Title = strings.TrimSpace(strings.ToLower(s.Text()))
-			})
-
-			item.Find(`div.col-lg-7 > div > p.time`).Each(func(i int, s *goquery.Selection) {
-				track.Hour, track.Minute = splitTimeString(s.Text())
-			})
-
-			item.Find(`div.col-lg-7 > div > ul > li:nth-child(2) > a`).Each(func(i int, s *goquery.Selection) {
-				track.SpotifyURL, _ = s.Attr("href")
-			})
-
-			item.Find(`div.col-lg-5 div img`).Each(func(i int, s *goquery.Selection) {
-				track.ImgURL, _ = s.Attr("src")
-			})
-
-			playlist.Tracks = append(playlist.Tracks, track)
-		})
+		nbrItems = len(tracks)
+		playlist.Tracks = append(playlist.Tracks, tracks...)
 
 		totalNbrItems += nbrItems
 		fmt.Println("Page:", page, "Number of Items:", nbrItems)
@@ -134,11 +107,55 @@ func GetPlaylist(date time.Time, nonce string) *Playlist {
 	return playlist
 }
 
+// ParsePlaylistPage extracts the tracks of a page of the playlist of a day
+// as returned by HTTPCache.GetPlaylistPage, the most recent play first.
+// Each track is a play, with the time it was played at.
+func ParsePlaylistPage(body []byte) ([]*Track, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the playlist page - %w", err)
+	}
+
+	var tracks []*Track
+	var timeErr error
+	doc.Find(`div.wwtt_content`).Each(func(i int, item *goquery.Selection) {
+		track := &Track{}
+		item.Find(`div.col-lg-7 > div > h2`).Each(func(i int, s *goquery.Selection) {
+			track.Artist = strings.Join(strings.Split(strings.ToLower(s.Text()), "/"), " and ")
+		})
+
+		item.Find(`div.col-lg-7 div p:not([class])`).Each(func(i int, s *goquery.Selection) {
+			track.Title = strings.TrimSpace(strings.ToLower(s.Text()))
+		})
+
+		item.Find(`div.col-lg-7 > div > p.time`).Each(func(i int, s *goquery.Selection) {
+			var err error
+			track.Hour, track.Minute, err = splitTimeString(s.Text())
+			if err != nil && timeErr == nil {
+				timeErr = err
+			}
+		})
+
+		item.Find(`div.col-lg-7 > div > ul > li:nth-child(2) > a`).Each(func(i int, s *goquery.Selection) {
+			track.SpotifyURL, _ = s.Attr("href")
+		})
+
+		item.Find(`div.col-lg-5 div img`).Each(func(i int, s *goquery.Selection) {
+			track.ImgURL, _ = s.Attr("src")
+		})
+
+		tracks = append(tracks, track)
+	})
+	if timeErr != nil {
+		return nil, fmt.Errorf("failed to parse the playlist page - %w", timeErr)
+	}
+	return tracks, nil
+}
+
 func GetPlaylists(startDate, endDate time.Time) ([]*Playlist, error) {
 	nonce, err := GetNonce()
 	if err != nil {
-		fmt.Println("Error getting the nonce:", err)
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to get the nonce - %w", err)
 	}
 	var playlists []*Playlist
 	fmt.Println("Getting the playlists for", startDate.String(), "to", endDate.String())
@@ -218,17 +235,26 @@ func CleanTitle(title string) string {
 	return t
 }
 
-func splitTimeString(timeStr string) (int, int) {
-	t := strings.Split(timeStr, ":")
+// Slugify returns a lowercase, URL and filename friendly version of s.
+func Slugify(s string) string {
+	return inflector.Parameterize(s, "-")
+}
+
+// splitTimeString returns the hour and minute of a HH:MM time.
+func splitTimeString(timeStr string) (int, int, error) {
+	t := strings.Split(strings.TrimSpace(timeStr), ":")
+	if len(t) != 2 {
+		return 0, 0, fmt.Errorf("invalid play time %q", timeStr)
+	}
 	h, err := strconv.Atoi(t[0])
 	if err != nil {
-		panic(err)
+		return 0, 0, fmt.Errorf("invalid play time %q - %w", timeStr, err)
 	}
 	m, err := strconv.Atoi(t[1])
 	if err != nil {
-		panic(err)
+		return 0, 0, fmt.Errorf("invalid play time %q - %w", timeStr, err)
 	}
-	return h, m
+	return h, m, nil
 }
 
 func FileExists(filename string) bool {
@@ -239,6 +265,34 @@ func FileExists(filename string) bool {
 	return !info.IsDir()
 }
 
+// WriteFileAtomic writes a file by writing to a temporary file in the same
+// directory, syncing it to disk and renaming it over path, so a crash
+// or a failed write never leaves a partially written file behind.
+func WriteFileAtomic(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary file for %s - %w", path, err)
+	}
+	// no-op once the file was renamed
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s - %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 func MonthEnglishName(month time.Month) string {
 
 	var monthName string
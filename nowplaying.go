@@ -0,0 +1,264 @@
+package nova
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// NowPlayingPath is the file where the plays of the day recorded by
+// NowPlaying are kept across restarts. It's not a playlist of the store:
+// the plays only seen while polling would make the playlist of the day
+// look complete and it would never be fetched.
+var NowPlayingPath = "data/now-playing.json"
+
+// Play is a track played on the radio.
+type Play struct {
+	Track    *Track
+	PlayedAt time.Time
+}
+
+// PlayJSON is the JSON representation of a play, as sent by the now playing endpoint.
+type PlayJSON struct {
+	ID           string    `json:"id"`
+	ArtistSlug   string    `json:"artistSlug"`
+	Artist       string    `json:"artist"`
+	Title        string    `json:"title"`
+	PlayedAt     time.Time `json:"playedAt"`
+	ThumbnailURL string    `json:"thumbnailUrl,omitempty"`
+	SpotifyURL   string    `json:"spotifyUrl,omitempty"`
+}
+
+// JSON returns the play in its JSON representation.
+func (p *Play) JSON() *PlayJSON {
+	return &PlayJSON{
+		ID:           p.Track.ID(),
+		ArtistSlug:   p.Track.ArtistSlug(),
+		Artist:       p.Track.Artist,
+		Title:        p.Track.Title,
+		PlayedAt:     p.PlayedAt,
+		ThumbnailURL: p.Track.ImgURL,
+		SpotifyURL:   p.Track.SpotifyURL,
+	}
+}
+
+// NowPlaying polls the first page of the playlist of the day to record the
+// plays as they happen in NowPlayingPath and notify its subscribers.
+type NowPlaying struct {
+	// Interval is the time between two polls.
+	Interval time.Duration
+	// Location is the time zone of the radio, the days start at midnight there.
+	Location *time.Location
+	// RecentSize is the number of plays kept for the new subscribers.
+	RecentSize int
+
+	// pollMu serializes the polls, it guards day, plays, seen and nonce.
+	pollMu sync.Mutex
+	// day is the date of the plays, as YYYY-MM-DD.
+	day string
+	// plays are the plays of the day recorded so far, the oldest first.
+	plays []*Play
+	// seen has the time and key of the plays already recorded.
+	seen  map[string]bool
+	nonce string
+
+	// mu guards the recent plays and the subscribers.
+	mu     sync.Mutex
+	recent []*Play
+	subs   map[chan *Play]bool
+}
+
+// NewNowPlaying returns a poller of the playlist of the radio in loc.
+func NewNowPlaying(interval time.Duration, loc *time.Location) *NowPlaying {
+	return &NowPlaying{
+		Interval:   interval,
+		Location:   loc,
+		RecentSize: 10,
+		subs:       make(map[chan *Play]bool),
+	}
+}
+
+// Run polls the playlist until ctx is done. A poll panicking is logged
+// like a failed one so it doesn't take the server down.
+func (n *NowPlaying) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.Interval)
+	defer ticker.Stop()
+	for {
+		if err := n.safePoll(); err != nil {
+			log.Println("Error polling the playlist:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *NowPlaying) safePoll() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("the poll panicked - %v", r)
+		}
+	}()
+	_, err = n.Poll()
+	return err
+}
+
+// Poll fetches the first page of the playlist of the day, records the plays
+// that weren't seen yet in NowPlayingPath and broadcasts them.
+// It returns the new plays, the oldest first.
+func (n *NowPlaying) Poll() ([]*Play, error) {
+	n.pollMu.Lock()
+	defer n.pollMu.Unlock()
+
+	now := time.Now().In(n.Location)
+	if day := now.Format("2006-01-02"); n.day != day {
+		n.loadDay(day)
+	}
+	if n.nonce == "" {
+		nonce, err := GetNonce()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the nonce - %w", err)
+		}
+		n.nonce = nonce
+	}
+
+	body, err := httpCache.GetFreshPlaylistPage(now, 1, n.nonce)
+	if err != nil {
+		// the nonce might have expired
+		n.nonce = ""
+		return nil, err
+	}
+	tracks, err := ParsePlaylistPage(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// the page has the most recent play first
+	var plays []*Play
+	for i := len(tracks) - 1; i >= 0; i-- {
+		track := tracks[i]
+		key := playKey(track)
+		if n.seen[key] {
+			continue
+		}
+		n.seen[key] = true
+		plays = append(plays, &Play{
+			Track:    track,
+			PlayedAt: time.Date(now.Year(), now.Month(), now.Day(), track.Hour, track.Minute, 0, 0, n.Location),
+		})
+	}
+	if len(plays) == 0 {
+		return nil, nil
+	}
+
+	n.plays = append(n.plays, plays...)
+	if err := n.save(); err != nil {
+		return plays, fmt.Errorf("failed to save the plays of the day - %w", err)
+	}
+	for _, play := range plays {
+		n.broadcast(play)
+	}
+	return plays, nil
+}
+
+// nowPlayingDocument is the content of NowPlayingPath.
+type nowPlayingDocument struct {
+	Day   string      `json:"day"`
+	Plays []*PlayJSON `json:"plays"`
+}
+
+// loadDay switches to the plays of day, loaded from NowPlayingPath if
+// they were recorded before a restart.
+func (n *NowPlaying) loadDay(day string) {
+	n.day = day
+	n.plays = nil
+	n.seen = make(map[string]bool)
+
+	var doc nowPlayingDocument
+	data, err := os.ReadFile(NowPlayingPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("Error reading the plays of the day:", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		log.Println("Error reading the plays of the day:", err)
+		return
+	}
+	if doc.Day != day {
+		return
+	}
+	for _, p := range doc.Plays {
+		playedAt := p.PlayedAt.In(n.Location)
+		play := &Play{
+			Track: &Track{
+				Artist:     p.Artist,
+				Title:      p.Title,
+				Hour:       playedAt.Hour(),
+				Minute:     playedAt.Minute(),
+				ImgURL:     p.ThumbnailURL,
+				SpotifyURL: p.SpotifyURL,
+			},
+			PlayedAt: playedAt,
+		}
+		n.plays = append(n.plays, play)
+		n.seen[playKey(play.Track)] = true
+	}
+}
+
+// save writes the plays of the day to NowPlayingPath.
+func (n *NowPlaying) save() error {
+	doc := nowPlayingDocument{Day: n.day, Plays: make([]*PlayJSON, 0, len(n.plays))}
+	for _, play := range n.plays {
+		doc.Plays = append(doc.Plays, play.JSON())
+	}
+	return WriteFileAtomic(NowPlayingPath, 0644, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	})
+}
+
+// playKey identifies a play of the day by its time and track.
+func playKey(t *Track) string {
+	return fmt.Sprintf("%02d:%02d|%s", t.Hour, t.Minute, t.Key())
+}
+
+func (n *NowPlaying) broadcast(play *Play) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.recent = append(n.recent, play)
+	if len(n.recent) > n.RecentSize {
+		n.recent = n.recent[len(n.recent)-n.RecentSize:]
+	}
+	for ch := range n.subs {
+		select {
+		case ch <- play:
+		default:
+			// the subscriber is too slow, it misses the play rather than blocking the others
+		}
+	}
+}
+
+// Subscribe returns the most recent plays, the oldest first, and a channel
+// receiving the new ones until the returned function is called.
+func (n *NowPlaying) Subscribe() (recent []*Play, plays <-chan *Play, unsubscribe func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ch := make(chan *Play, 16)
+	n.subs[ch] = true
+	recent = append([]*Play(nil), n.recent...)
+	return recent, ch, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subs, ch)
+	}
+}
@@ -1,14 +1,14 @@
 package nova
 
 import (
-	"bytes"
-	"encoding/gob"
 	"fmt"
-	"html/template"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,89 +16,6 @@ var (
 	PlaylistDataPath = "./data"
 )
 
-var HTMLTmpl = `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>Radio Nova {{.Name}} - Playlist</title>
-    <link rel="stylesheet" type="text/css" href="playlist.css">
-    <link href="https://fonts.googleapis.com/css2?family=Open+Sans&display=swap" rel="stylesheet">
-
-		<!-- Tailwind CSS -->
-    <script src="https://cdn.tailwindcss.com"></script>
-
-    <!-- Core dependencies -->
-		<script src="https://cdnjs.cloudflare.com/ajax/libs/react/18.2.0/umd/react.production.min.js"></script>
-		<script src="https://cdnjs.cloudflare.com/ajax/libs/react-dom/18.2.0/umd/react-dom.production.min.js"></script>
-
-		<!-- Lucide Icons (global version) -->
-		<script src="https://unpkg.com/lucide@latest"></script>
-
-    <!-- Error handling for script loading -->
-    <script>
-        window.addEventListener('error', function(e) {
-            if (e.target.tagName === 'SCRIPT') {
-                console.error('Failed to load script:', e.target.src);
-            }
-        }, true);
-    </script>
-</head>
-<body>
-    <h1>Radio Nova {{.Title}}</h1>
-    <nav>
-        {{ .PrevLink | unescapeHTML }}
-        <a href="./">All Playlists</a>
-        {{ .NextLink | unescapeHTML }}
-    </nav>
-
-    <table class="playlist">
-        <tbody class="playlist">
-            {{$playlist := .}}
-            {{range $index, $track := .Tracks}}
-            {{$previousRanking := $playlist.PreviousRanking $track}}
-            <tr class="playlist-entry" data-title="{{.Title}}">
-                <td class="position"><span>{{addOne $index}}</span></td>
-                <td class="rankinkDelta">
-                {{if gt $previousRanking -1}}
-                    {{ rankingDelta $index $previousRanking }}</span>
-                {{ end }}
-                </td>
-                <td class="artwork">
-                    <a href="{{.YTMusicURL}}" target="_blank"><img src="{{.ThumbURL}}" class="artwork" loading="lazy" /></a>
-                </td>
-                <td class="track">
-                    <a href="{{.YTMusicURL}}" target="_blank"><span class="title">{{.Title}}</span></a>
-                    by <a href="{{.YTPrimaryArtistURL}}" target="_blank"><span class="artist-name">{{.Artist}}</span></a>
-                </td>
-                <td class="duration">
-                    <span class="duration">{{.YTDuration}}</span>
-                </td>
-                <td class="dsp-links">
-                    <a class="ytmusic" href="{{.YTMusicURL}}" target="_blank"><img src="images/youtube-music.svg"/></a>
-                    <a class="spotify" href="{{.SpotifyURL}}" target="_blank"><img src="images/spotify.svg"/></a>
-                </td>
-                <td class="playcount" data-count={{.Count}}>
-                {{if gt .Count 20}}
-                    <img src="images/flame-icon.svg" alt="{{.Count}} plays"/>
-                {{end}}
-                </td>
-            </tr>
-            {{end}}
-        </tbody>
-    </table>
-
-    <!-- Nova Player Component -->
-		<div id="nova-player-root"></div>
-
-    <!-- Initialize YouTube IFrame API -->
-    <script src="https://www.youtube.com/iframe_api"></script>
-
-    <!-- Add NovaPlayer Component -->
-    <script src="nova-player.js"></script>
-</body>
-</html>
-`
-
 type Playlist struct {
 	Tracks           []*Track
 	Name             string
@@ -156,47 +73,32 @@ func (p *Playlist) String() string {
 	return s.String()
 }
 
-// the path in which the playlist can be saved/loaded from
+// the path in which the playlist can be saved/loaded from, see FSStore
 func (p *Playlist) Path() string {
-	if p.Name != "" {
-		return PlaylistDataPath
-	}
-	path := PlaylistDataPath
-	if p.Year > 0 {
-		path = filepath.Join(path, fmt.Sprintf("%d", p.Year))
-	}
-	if p.Month > 0 {
-		path = filepath.Join(path, fmt.Sprintf("%02d", p.Month))
-	}
-	return path
+	return filepath.Dir(p.storePath())
 }
 
 func (p *Playlist) Filename() string {
-	filename := "playlist"
 	if p == nil {
-		return filename + ".gob"
-	}
-	// if the playlist has a name, use that as the filename
-	if p.Name != "" {
-		return filename + "-" + p.Name + ".gob"
+		return "playlist.gob"
 	}
+	return filepath.Base(p.storePath())
+}
 
-	if p.Year > 0 {
-		filename = fmt.Sprintf("%s-%d", filename, p.Year)
-	}
-	if p.Month > 0 {
-		filename = fmt.Sprintf("%s-%02d", filename, p.Month)
-	}
-	if p.Day > 0 {
-		filename = fmt.Sprintf("%s-%02d", filename, p.Day)
-	}
-	return filename + ".gob"
+func (p *Playlist) storePath() string {
+	return defaultStore.Path(p.StoreKey())
 }
 
+// OldFilename is the filename of the monthly playlists in the legacy layout.
+//
+// Deprecated: the playlists are stored in the FSStore layout, `nova migrate`
+// moves the legacy files there.
 func (p *Playlist) OldFilename() string {
 	return fmt.Sprintf("playlist-%s.gob", p.Name)
 }
 
+// LoadPlaylistFromFile loads a playlist in the storage format of its
+// extension, gob when it doesn't have the extension of one.
 func LoadPlaylistFromFile(filepath string) (*Playlist, error) {
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -204,72 +106,194 @@ func LoadPlaylistFromFile(filepath string) (*Playlist, error) {
 	}
 	defer file.Close()
 
-	p := &Playlist{}
-
-	// decode the file into playlist
-	decoder := gob.NewDecoder(file)
-	if err := decoder.Decode(p); err != nil {
-		return nil, fmt.Errorf("failed to decode the binary file %w", err)
+	format, ok := StorageFormatOf(filepath)
+	if !ok {
+		format = GobFormat
 	}
-
-	return p, nil
+	return DecodePlaylist(file, format)
 }
 
+// LoadFromDisk loads the playlist with the date (or the name) of p from Playlists.
 func (p *Playlist) LoadFromDisk() error {
-	file, err := os.Open(filepath.Join(p.Path(), p.Filename()))
+	loaded, err := Playlists.Load(p.StoreKey())
 	if err != nil {
-		return fmt.Errorf("failed to open the file from disk %w", err)
-	}
-	defer file.Close()
-
-	// decode the file into playlist
-	decoder := gob.NewDecoder(file)
-	if err := decoder.Decode(p); err != nil {
-		return fmt.Errorf("failed to decode the binary file %w", err)
+		return err
 	}
-
+	*p = *loaded
 	return nil
 }
 
+// SaveToDisk saves the playlist to Playlists.
 func (p *Playlist) SaveToDisk() error {
-	// path relative to this binary
-	destPath := filepath.Join(p.Path(), p.Filename())
-	fmt.Println("> saving playlist to", destPath)
-	// check if directory exists
-	dir := filepath.Dir(destPath)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		err = os.MkdirAll(dir, 0700)
-		if err != nil {
-			return fmt.Errorf("failed to make sure all directories were created - %w", err)
-		}
-	}
+	fmt.Println("> saving playlist", p.StoreKey())
+	return Playlists.Save(p)
+}
 
-	file, err := os.Create(destPath)
+// SaveToFile saves the playlist to path in the storage format of its
+// extension (gob by default), atomically so a failed save doesn't leave
+// a truncated file behind.
+func (p *Playlist) SaveToFile(path string) error {
+	format, ok := StorageFormatOf(path)
+	if !ok {
+		format = GobFormat
+	}
+	err := WriteFileAtomic(path, 0644, func(w io.Writer) error {
+		return EncodePlaylist(w, p, format)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create the file: %s - %w", destPath, err)
+		return fmt.Errorf("failed to save the playlist to %s - %w", path, err)
 	}
-	defer file.Close()
+	return nil
+}
 
-	encoder := gob.NewEncoder(file)
-	if err := encoder.Encode(p); err != nil {
-		return err
+// YTWorkers is the number of concurrent YT Music lookups PopulateYTIDs runs.
+var YTWorkers = 8
+
+// YTProgress reports how far along a YT Music lookup run is.
+type YTProgress struct {
+	Done      int
+	Total     int
+	CacheHits int
+	Elapsed   time.Duration
+}
+
+// HitRate returns the ratio of lookups resolved without a new search.
+func (p YTProgress) HitRate() float64 {
+	if p.Done == 0 {
+		return 0
 	}
-	return nil
+	return float64(p.CacheHits) / float64(p.Done)
+}
+
+// ETA estimates the time left based on the pace so far.
+func (p YTProgress) ETA() time.Duration {
+	if p.Done == 0 {
+		return 0
+	}
+	perLookup := p.Elapsed / time.Duration(p.Done)
+	return perLookup * time.Duration(p.Total-p.Done)
+}
+
+func (p YTProgress) String() string {
+	return fmt.Sprintf("%d/%d (cache hits: %.0f%%, ETA: %s)", p.Done, p.Total, p.HitRate()*100, p.ETA().Round(time.Second))
 }
 
 func (p *Playlist) PopulateYTIDs() error {
-	for i, track := range p.Tracks {
+	return p.PopulateYTIDsWithProgress(nil)
+}
+
+// PopulateYTIDsWithProgress looks up the YT Music info of the tracks missing it
+// using YTWorkers concurrent workers. progress, if not nil, is called after
+// each lookup, never concurrently.
+func (p *Playlist) PopulateYTIDsWithProgress(progress func(YTProgress)) error {
+	if YTMusic == nil {
+		return fmt.Errorf("YT Music cache is not loaded, load it first using nova.LoadYTMusicCache()")
+	}
+	var missing []*Track
+	for _, track := range p.Tracks {
 		if track.YTMusicInfo == nil {
-			track.YTMusicInfo = track.GetYTMusicInfo()
-			p.Tracks[i] = track
+			missing = append(missing, track)
+		}
+	}
+
+	lookupTracks(missing, progress, func(track *Track) bool {
+		info, hit, err := YTMusic.trackInfo(track.ytQuery())
+		if err != nil {
+			log.Println(err)
+			return hit
+		}
+		track.YTMusicInfo = info
+		return hit
+	})
+	return nil
+}
+
+// PopulateYTArtistIDs resolves the YT Music artist of the tracks whose match
+// doesn't include it and stores it in Track.YTArtistID.
+// progress, if not nil, is called after each lookup, never concurrently.
+func (p *Playlist) PopulateYTArtistIDs(progress func(YTProgress)) error {
+	if YTMusic == nil {
+		return fmt.Errorf("YT Music cache is not loaded, load it first using nova.LoadYTMusicCache()")
+	}
+	var missing []*Track
+	for _, track := range p.Tracks {
+		if track.YTMusicInfo != nil && track.YTArtistBrowseID() == "" {
+			missing = append(missing, track)
 		}
 	}
+
+	lookupTracks(missing, progress, func(track *Track) bool {
+		hit := YTMusic.match(track.Artist) != nil
+		info, err := YTMusic.ArtistInfo(track.Artist)
+		if err != nil || info == nil || info.BrowseID == "" {
+			log.Println("Failed to find YT artist info for", track.Artist)
+			return hit
+		}
+		track.YTArtistID = info.BrowseID
+		return hit
+	})
 	return nil
 }
 
+// lookupTracks runs lookup on each track using YTWorkers goroutines,
+// checkpointing the YT Music cache every YTMusicCheckpointInterval.
+// lookup returns true when the track was resolved from the cache.
+func lookupTracks(tracks []*Track, progress func(YTProgress), lookup func(*Track) bool) {
+	workers := YTWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		wg             sync.WaitGroup
+		mu             sync.Mutex
+		status         = YTProgress{Total: len(tracks)}
+		start          = time.Now()
+		lastCheckpoint = start
+		jobs           = make(chan *Track)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for track := range jobs {
+				hit := lookup(track)
+
+				mu.Lock()
+				status.Done++
+				if hit {
+					status.CacheHits++
+				}
+				status.Elapsed = time.Since(start)
+				if progress != nil {
+					progress(status)
+				}
+				// save what we have so far so a crash doesn't lose all the lookups
+				checkpoint := YTMusicCheckpointInterval > 0 && time.Since(lastCheckpoint) > YTMusicCheckpointInterval
+				if checkpoint {
+					lastCheckpoint = time.Now()
+				}
+				mu.Unlock()
+
+				if checkpoint {
+					if err := YTMusic.Checkpoint(); err != nil {
+						log.Println("failed to checkpoint the YT music cache:", err)
+					}
+				}
+			}
+		}()
+	}
+
+	for _, track := range tracks {
+		jobs <- track
+	}
+	close(jobs)
+	wg.Wait()
+}
+
 func (p *Playlist) AddTracks(tracks []*Track) {
-	var found bool
 	for _, trackToAdd := range tracks {
+		found := false
 		for i, t := range p.Tracks {
 			if t.Key() == trackToAdd.Key() {
 				p.Tracks[i].Count++
@@ -296,6 +320,12 @@ func (p *Playlist) Title() string {
 	return p.Name
 }
 
+// Basename is the name used for the files generated for the playlist
+// (e.g. March-2025.html), which can't contain spaces.
+func (p *Playlist) Basename() string {
+	return strings.ReplaceAll(p.Name, " ", "")
+}
+
 func (p *Playlist) PreviousRanking(track *Track) int {
 	if p == nil || p.PreviousPlaylist == nil {
 		return -1
@@ -308,49 +338,9 @@ func (p *Playlist) PreviousRanking(track *Track) int {
 	return -1
 }
 
+// ToHTML renders the playlist page with the playlist.html template of Templates.
 func (p *Playlist) ToHTML() ([]byte, error) {
-	// TODO: check if we have a previous/next playlist
-	// get the previous playlist to get the ranking changes
-	t, err := template.New("playlist").Funcs(template.FuncMap{
-		"addOne": func(n int) int {
-			return n + 1
-		},
-		"unescapeHTML": func(s string) template.HTML {
-			return template.HTML(s)
-		},
-		"minus": func(a, b int) int {
-			return a - b
-		},
-		"rankingDelta": func(newPosition, oldPosition int) template.HTML {
-			if oldPosition == -1 {
-				return ""
-			}
-			if newPosition < oldPosition {
-				diff := oldPosition - newPosition
-				return template.HTML(fmt.Sprintf(`<div class="ranking-delta up">
-  <svg xmlns="http://www.w3.org/2000/svg" height="48" width="48"><path class="arrow-up" d="m24 30-10-9.95h20Z"></path></svg>
-  <span class="ranking-delta-num">%d</span>
-</div>`, diff))
-			} else {
-				diff := newPosition - oldPosition
-				return template.HTML(fmt.Sprintf(`<div class="ranking-delta down">
-				<span class="ranking-delta-num">%d</span>
-				<svg xmlns="http://www.w3.org/2000/svg" height="48" width="48"><path class="arrow-down" d="m24 30-10-9.95h20Z"></path></svg>
-			</div>`, diff))
-			}
-		},
-	}).Parse(HTMLTmpl)
-	if err != nil {
-		return nil, err
-	}
-
-	var buf bytes.Buffer
-	err = t.Execute(&buf, p)
-	if err != nil {
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
+	return Templates.Render("playlist.html", p)
 }
 
 func addOne(n int) int {
@@ -362,7 +352,7 @@ func (p *Playlist) PrevLink() string {
 		return ""
 	}
 
-	return fmt.Sprintf(`<a href="%s" class="prev">%s</a>`, p.PreviousPlaylist.Name+".html", p.PreviousPlaylist.Title())
+	return fmt.Sprintf(`<a href="%s" class="prev">%s</a>`, p.PreviousPlaylist.Basename()+".html", p.PreviousPlaylist.Title())
 }
 
 func (p *Playlist) NextLink() string {
@@ -370,5 +360,5 @@ func (p *Playlist) NextLink() string {
 		return ""
 	}
 
-	return fmt.Sprintf(`<a href="%s" class="next">%s</a>`, p.NextPlaylist.Name+".html", p.NextPlaylist.Title())
+	return fmt.Sprintf(`<a href="%s" class="next">%s</a>`, p.NextPlaylist.Basename()+".html", p.NextPlaylist.Title())
 }
@@ -0,0 +1,29 @@
+package nova
+
+import "testing"
+
+func TestAddTracks(t *testing.T) {
+	a := func() *Track { return &Track{Artist: "Air", Title: "Playground Love"} }
+	b := func() *Track { return &Track{Artist: "Daft Punk", Title: "Digital Love"} }
+	c := func() *Track { return &Track{Artist: "Justice", Title: "D.A.N.C.E."} }
+
+	p := &Playlist{}
+	p.AddTracks([]*Track{a(), a(), b()})
+	p.AddTracks([]*Track{c(), b(), a()})
+
+	// A track played after a duplicate used to be dropped: only the
+	// counts of the tracks already in the playlist went up.
+	want := map[string]int{
+		a().Key(): 3,
+		b().Key(): 2,
+		c().Key(): 1,
+	}
+	if len(p.Tracks) != len(want) {
+		t.Fatalf("got %d tracks, want %d", len(p.Tracks), len(want))
+	}
+	for _, track := range p.Tracks {
+		if track.Count != want[track.Key()] {
+			t.Errorf("%s: got %d plays, want %d", track.Key(), track.Count, want[track.Key()])
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package nova
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PlayFilter selects plays of the daily playlists, its zero value selects
+// all of them.
+type PlayFilter struct {
+	// Artist and Title select the plays whose artist or title contain them,
+	// ignoring the case.
+	Artist, Title string
+	// From and To are the first and last days of the plays, unbounded when zero.
+	From, To time.Time
+	// Hours selects the plays started during the given hours, all of them when empty.
+	Hours map[int]bool
+	// Weekdays selects the plays of the given days of the week, all of them when empty.
+	Weekdays map[time.Weekday]bool
+}
+
+// Match reports if the play is selected by the filter.
+func (f *PlayFilter) Match(p *Play) bool {
+	if !f.matchDay(p.PlayedAt) {
+		return false
+	}
+	// the hour of the playlist, PlayedAt moves the plays of the hour skipped
+	// when switching to summer time to the next one
+	if len(f.Hours) > 0 && !f.Hours[p.Track.Hour] {
+		return false
+	}
+	if f.Artist != "" && !strings.Contains(strings.ToLower(p.Track.Artist), strings.ToLower(f.Artist)) {
+		return false
+	}
+	if f.Title != "" && !strings.Contains(strings.ToLower(p.Track.Title), strings.ToLower(f.Title)) {
+		return false
+	}
+	return true
+}
+
+// matchDay reports if the plays of the day can be selected by the filter.
+func (f *PlayFilter) matchDay(t time.Time) bool {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if !f.From.IsZero() && day.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && day.After(f.To) {
+		return false
+	}
+	return len(f.Weekdays) == 0 || f.Weekdays[day.Weekday()]
+}
+
+// LoadPlays loads the plays of the daily playlists of the store selected by
+// filter, chronologically. The plays are at the time of the station in loc,
+// only the days which can match are loaded.
+func LoadPlays(store Store, filter *PlayFilter, loc *time.Location) ([]*Play, error) {
+	keys, err := store.List(DailyPlaylists)
+	if err != nil {
+		return nil, err
+	}
+	var plays []*Play
+	for _, key := range keys {
+		if !filter.matchDay(time.Date(key.Year, time.Month(key.Month), key.Day, 0, 0, 0, 0, time.UTC)) {
+			continue
+		}
+		playlist, err := store.Load(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the playlist %s - %w", key, err)
+		}
+		var day []*Play
+		for _, track := range playlist.Tracks {
+			if track == nil {
+				continue
+			}
+			play := &Play{
+				Track:    track,
+				PlayedAt: time.Date(key.Year, time.Month(key.Month), key.Day, track.Hour, track.Minute, 0, 0, loc),
+			}
+			if filter.Match(play) {
+				day = append(day, play)
+			}
+		}
+		// the daily playlists list the latest plays first
+		sort.SliceStable(day, func(i, j int) bool {
+			return day[i].PlayedAt.Before(day[j].PlayedAt)
+		})
+		plays = append(plays, day...)
+	}
+	return plays, nil
+}
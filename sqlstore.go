@@ -0,0 +1,386 @@
+package nova
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"sort"
+)
+
+// SQLiteDriver is the name of the database/sql driver used by OpenSQLStore.
+// The program registers it by importing a SQLite driver, e.g. the pure Go
+// modernc.org/sqlite, so the nova package doesn't depend on one.
+const SQLiteDriver = "sqlite"
+
+// sqlSchemaVersion is the version of sqlSchema, kept in the user_version of
+// the database.
+const sqlSchemaVersion = 1
+
+// sqlSchema are the tables of a SQLStore. The playlists keep their JSON
+// document (see StorageSchemaVersion) so they are loaded as they were saved,
+// the other tables split them up to be queried:
+//
+//	SELECT a.name, t.title, COUNT(*) FROM plays p
+//	JOIN tracks t ON t.id = p.track_id JOIN artists a ON a.id = t.artist_id
+//	WHERE p.played_at >= '2023' AND p.played_at < '2024' AND p.hour BETWEEN 2 AND 4
+//	GROUP BY t.id ORDER BY 3 DESC
+const sqlSchema = `
+CREATE TABLE IF NOT EXISTS artists (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	-- YT Music browse ID of the artist
+	yt_artist_id TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS tracks (
+	id INTEGER PRIMARY KEY,
+	-- Track.Key(), the artist and title
+	key TEXT NOT NULL UNIQUE,
+	artist_id INTEGER NOT NULL REFERENCES artists(id),
+	title TEXT NOT NULL,
+	img_url TEXT NOT NULL DEFAULT '',
+	spotify_url TEXT NOT NULL DEFAULT '',
+	yt_artist_id TEXT NOT NULL DEFAULT ''
+);
+-- the YT Music matches of the tracks
+CREATE TABLE IF NOT EXISTS matches (
+	track_id INTEGER PRIMARY KEY REFERENCES tracks(id),
+	video_id TEXT NOT NULL,
+	title TEXT NOT NULL,
+	album TEXT NOT NULL,
+	duration INTEGER NOT NULL,
+	explicit INTEGER NOT NULL,
+	-- the whole match as JSON
+	item TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS playlists (
+	id INTEGER PRIMARY KEY,
+	year INTEGER NOT NULL,
+	month INTEGER NOT NULL,
+	day INTEGER NOT NULL,
+	-- only set for the playlists without a year, see PlaylistKey
+	name TEXT NOT NULL,
+	document BLOB NOT NULL,
+	UNIQUE (year, month, day, name)
+);
+-- the tracks of the playlists: the plays of the daily playlists, the
+-- tracks and their number of plays of the monthly ones
+CREATE TABLE IF NOT EXISTS plays (
+	playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	track_id INTEGER NOT NULL REFERENCES tracks(id),
+	-- 2025-03-14 21:30 in the daily playlists, NULL otherwise
+	played_at TEXT,
+	hour INTEGER NOT NULL,
+	minute INTEGER NOT NULL,
+	count INTEGER NOT NULL,
+	PRIMARY KEY (playlist_id, position)
+);
+CREATE INDEX IF NOT EXISTS plays_track ON plays (track_id);
+CREATE INDEX IF NOT EXISTS plays_played_at ON plays (played_at);
+`
+
+// SQLStore stores the playlists in a SQLite database, see sqlSchema.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// OpenSQLStore opens the SQLite database at path, creating it if needed.
+// The SQLiteDriver must be registered.
+func OpenSQLStore(path string) (*SQLStore, error) {
+	// the path is escaped since SQLite parses it as a URI, where ? and # have a meaning
+	db, err := sql.Open(SQLiteDriver, "file:"+url.PathEscape(path)+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the database %s - %w", path, err)
+	}
+	// SQLite only has one writer at a time
+	db.SetMaxOpenConns(1)
+	store, err := NewSQLStore(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open the database %s - %w", path, err)
+	}
+	return store, nil
+}
+
+// NewSQLStore returns a store in db, creating its tables if needed.
+func NewSQLStore(db *sql.DB) (*SQLStore, error) {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return nil, err
+	}
+	if version > sqlSchemaVersion {
+		return nil, fmt.Errorf("the database has the schema version %d, this version only supports up to %d", version, sqlSchemaVersion)
+	}
+	if _, err := db.Exec(sqlSchema); err != nil {
+		return nil, fmt.Errorf("failed to create the tables - %w", err)
+	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", sqlSchemaVersion)); err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// DB returns the database of the store, to run queries on it.
+func (s *SQLStore) DB() *sql.DB {
+	return s.db
+}
+
+// DataVersion changes when the database is written to, by this store or by
+// another program, so it can be polled for changes.
+func (s *SQLStore) DataVersion() (string, error) {
+	ctx := context.Background()
+	// both counters are the ones of a connection
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	var others, own int64
+	if err := conn.QueryRowContext(ctx, "PRAGMA data_version").Scan(&others); err != nil {
+		return "", err
+	}
+	if err := conn.QueryRowContext(ctx, "SELECT total_changes()").Scan(&own); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", others, own), nil
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) Load(key PlaylistKey) (*Playlist, error) {
+	var document []byte
+	err := s.db.QueryRow("SELECT document FROM playlists WHERE year = ? AND month = ? AND day = ? AND name = ?",
+		key.Year, key.Month, key.Day, key.Name).Scan(&document)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no playlist %s in the database - %w", key, fs.ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the playlist %s - %w", key, err)
+	}
+	return DecodePlaylist(bytes.NewReader(document), JSONFormat)
+}
+
+// Save saves the playlist and its tracks in a transaction. The tracks,
+// artists and matches shared with other playlists are updated with the
+// values of this one which aren't empty.
+func (s *SQLStore) Save(p *Playlist) error {
+	var document bytes.Buffer
+	if err := EncodePlaylist(&document, p, JSONFormat); err != nil {
+		return err
+	}
+	key := p.StoreKey()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var playlistID int64
+	err = tx.QueryRow(`INSERT INTO playlists (year, month, day, name, document) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (year, month, day, name) DO UPDATE SET document = excluded.document
+		RETURNING id`, key.Year, key.Month, key.Day, key.Name, document.Bytes()).Scan(&playlistID)
+	if err != nil {
+		return fmt.Errorf("failed to save the playlist %s - %w", key, err)
+	}
+	if _, err := tx.Exec("DELETE FROM plays WHERE playlist_id = ?", playlistID); err != nil {
+		return err
+	}
+
+	w, err := newSQLTrackWriter(tx)
+	if err != nil {
+		return err
+	}
+	defer w.close()
+	position := 0
+	for _, track := range p.Tracks {
+		if track == nil {
+			continue
+		}
+		trackID, err := w.save(track)
+		if err != nil {
+			return fmt.Errorf("failed to save the track %s of the playlist %s - %w", track.Key(), key, err)
+		}
+		var playedAt any
+		if key.Daily() {
+			playedAt = fmt.Sprintf("%s %02d:%02d", key, track.Hour, track.Minute)
+		}
+		if _, err := w.plays.Exec(playlistID, position, trackID, playedAt, track.Hour, track.Minute, track.Count); err != nil {
+			return fmt.Errorf("failed to save the playlist %s - %w", key, err)
+		}
+		position++
+	}
+	return tx.Commit()
+}
+
+// sqlTrackWriter saves the tracks of a playlist, once per transaction.
+type sqlTrackWriter struct {
+	artists, tracks, matches, plays *sql.Stmt
+	ids                             map[string]int64
+}
+
+func newSQLTrackWriter(tx *sql.Tx) (*sqlTrackWriter, error) {
+	w := &sqlTrackWriter{ids: make(map[string]int64)}
+	var err error
+	prepare := func(query string) *sql.Stmt {
+		if err != nil {
+			return nil
+		}
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare(query)
+		return stmt
+	}
+	w.artists = prepare(`INSERT INTO artists (name, yt_artist_id) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET yt_artist_id = iif(excluded.yt_artist_id != '', excluded.yt_artist_id, yt_artist_id)
+		RETURNING id`)
+	w.tracks = prepare(`INSERT INTO tracks (key, artist_id, title, img_url, spotify_url, yt_artist_id) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			img_url = iif(excluded.img_url != '', excluded.img_url, img_url),
+			spotify_url = iif(excluded.spotify_url != '', excluded.spotify_url, spotify_url),
+			yt_artist_id = iif(excluded.yt_artist_id != '', excluded.yt_artist_id, yt_artist_id)
+		RETURNING id`)
+	w.matches = prepare(`INSERT OR REPLACE INTO matches (track_id, video_id, title, album, duration, explicit, item) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	w.plays = prepare(`INSERT INTO plays (playlist_id, position, track_id, played_at, hour, minute, count) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		w.close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *sqlTrackWriter) close() {
+	for _, stmt := range []*sql.Stmt{w.artists, w.tracks, w.matches, w.plays} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// save saves the track, its artist and match, returning its ID.
+func (w *sqlTrackWriter) save(t *Track) (int64, error) {
+	if id, ok := w.ids[t.Key()]; ok {
+		return id, nil
+	}
+	var artistID, trackID int64
+	if err := w.artists.QueryRow(t.Artist, t.YTArtistBrowseID()).Scan(&artistID); err != nil {
+		return 0, err
+	}
+	err := w.tracks.QueryRow(t.Key(), artistID, t.Title, t.ImgURL, t.SpotifyURL, t.YTArtistID).Scan(&trackID)
+	if err != nil {
+		return 0, err
+	}
+	if info := t.YTMusicInfo; info != nil {
+		item, err := json.Marshal(newYTMatchDocument(info))
+		if err != nil {
+			return 0, err
+		}
+		if _, err := w.matches.Exec(trackID, info.VideoID, info.Title, info.Album.Name, info.Duration, info.IsExplicit, item); err != nil {
+			return 0, err
+		}
+	}
+	w.ids[t.Key()] = trackID
+	return trackID, nil
+}
+
+func (s *SQLStore) List(filter func(PlaylistKey) bool) ([]PlaylistKey, error) {
+	rows, err := s.db.Query("SELECT year, month, day, name FROM playlists")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the playlists - %w", err)
+	}
+	defer rows.Close()
+	var keys []PlaylistKey
+	for rows.Next() {
+		var key PlaylistKey
+		if err := rows.Scan(&key.Year, &key.Month, &key.Day, &key.Name); err != nil {
+			return nil, err
+		}
+		if filter == nil || filter(key) {
+			keys = append(keys, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortPlaylistKeys(keys)
+	return keys, nil
+}
+
+func (s *SQLStore) Delete(key PlaylistKey) error {
+	res, err := s.db.Exec("DELETE FROM playlists WHERE year = ? AND month = ? AND day = ? AND name = ?",
+		key.Year, key.Month, key.Day, key.Name)
+	if err != nil {
+		return fmt.Errorf("failed to delete the playlist %s - %w", key, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no playlist %s in the database - %w", key, fs.ErrNotExist)
+	}
+	return nil
+}
+
+// Chart sums the plays of the tracks of the monthly playlists of year, of
+// all the years when it's 0, in the database instead of loading them.
+func (s *SQLStore) Chart(year int) ([]*Track, error) {
+	rows, err := s.db.Query(`SELECT a.name, t.title, t.img_url, t.spotify_url, t.yt_artist_id, m.item, SUM(p.count) AS plays
+		FROM plays p
+		JOIN playlists l ON l.id = p.playlist_id
+		JOIN tracks t ON t.id = p.track_id
+		JOIN artists a ON a.id = t.artist_id
+		LEFT JOIN matches m ON m.track_id = t.id
+		WHERE l.month > 0 AND l.day = 0 AND (?1 = 0 OR l.year = ?1)
+		GROUP BY t.id
+		ORDER BY plays DESC, MIN(l.year * 100 + l.month), MIN(p.position)`, year)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the chart - %w", err)
+	}
+	defer rows.Close()
+	var tracks []*Track
+	for rows.Next() {
+		track := &Track{}
+		var item sql.NullString
+		if err := rows.Scan(&track.Artist, &track.Title, &track.ImgURL, &track.SpotifyURL, &track.YTArtistID, &item, &track.Count); err != nil {
+			return nil, err
+		}
+		if item.Valid {
+			var match ytMatchDocument
+			if err := json.Unmarshal([]byte(item.String), &match); err != nil {
+				return nil, fmt.Errorf("failed to decode the match of %s - %w", track.Key(), err)
+			}
+			track.YTMusicInfo = match.trackItem()
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, rows.Err()
+}
+
+// ChartStore is a Store summing the plays of the monthly playlists itself.
+type ChartStore interface {
+	Store
+	// Chart returns the tracks of the monthly playlists of year, of all the
+	// years when it's 0, with their total plays, sorted by plays.
+	Chart(year int) ([]*Track, error)
+}
+
+var _ ChartStore = (*SQLStore)(nil)
+
+func sortPlaylistKeys(keys []PlaylistKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Year != b.Year {
+			return a.Year < b.Year
+		}
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		return a.Name < b.Name
+	})
+}
@@ -0,0 +1,305 @@
+package nova
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/raitonoberu/ytmusic"
+)
+
+// StorageFormat is an encoding of the playlists saved to files.
+type StorageFormat string
+
+const (
+	// GobFormat is the Go specific binary encoding of Playlist.
+	GobFormat StorageFormat = "gob"
+	// JSONFormat is the versioned JSON document described by StorageSchemaVersion.
+	JSONFormat StorageFormat = "json"
+	// JSONGzipFormat is JSONFormat compressed with gzip.
+	JSONGzipFormat StorageFormat = "json.gz"
+)
+
+// StorageFormats are the supported formats.
+var StorageFormats = []StorageFormat{GobFormat, JSONFormat, JSONGzipFormat}
+
+// Ext is the extension of the files in the format.
+func (f StorageFormat) Ext() string {
+	return "." + string(f)
+}
+
+// ParseStorageFormat returns the format with the given name (e.g. json.gz).
+func ParseStorageFormat(name string) (StorageFormat, error) {
+	for _, f := range StorageFormats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown storage format %q, use gob, json or json.gz", name)
+}
+
+// StorageFormatOf returns the format of a playlist file from its extension,
+// false when it isn't the one of a supported format.
+func StorageFormatOf(filename string) (StorageFormat, bool) {
+	// json.gz first since .gz files would otherwise not match
+	for _, f := range []StorageFormat{JSONGzipFormat, JSONFormat, GobFormat} {
+		if strings.HasSuffix(filename, f.Ext()) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// StorageSchemaVersion is the version of the JSON documents of the playlists.
+// Bump it on breaking changes and add the function upgrading the documents
+// of the previous version to schemaUpgrades.
+const StorageSchemaVersion = 1
+
+// schemaUpgrades upgrade the documents of the previous schema versions when
+// they are read: schemaUpgrades[v-1] turns a document of version v into one
+// of version v+1, so there's one function less than versions.
+var schemaUpgrades = []func(doc map[string]any) error{}
+
+func init() {
+	if len(schemaUpgrades) != StorageSchemaVersion-1 {
+		panic("missing upgrade functions for the playlist storage schema")
+	}
+}
+
+// playlistDocument is the JSON document of a playlist. Unlike the gob
+// encoding, it doesn't depend on the types of the ytmusic package.
+type playlistDocument struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Name          string          `json:"name,omitempty"`
+	Year          int             `json:"year,omitempty"`
+	Month         int             `json:"month,omitempty"`
+	Day           int             `json:"day,omitempty"`
+	Yearly        bool            `json:"yearly,omitempty"`
+	Tracks        []trackDocument `json:"tracks"`
+}
+
+type trackDocument struct {
+	Artist string `json:"artist"`
+	Title  string `json:"title"`
+	Date   string `json:"date,omitempty"`
+	Time   string `json:"time,omitempty"`
+	// Hour and Minute are the time of the play in the daily playlists.
+	Hour       int    `json:"hour,omitempty"`
+	Minute     int    `json:"minute,omitempty"`
+	ImgURL     string `json:"imgUrl,omitempty"`
+	SpotifyURL string `json:"spotifyUrl,omitempty"`
+	// Count is the number of plays in the monthly playlists.
+	Count      int              `json:"count,omitempty"`
+	YTMusic    *ytMatchDocument `json:"ytMusic,omitempty"`
+	YTArtistID string           `json:"ytArtistId,omitempty"`
+}
+
+type ytMatchDocument struct {
+	VideoID    string              `json:"videoId"`
+	PlaylistID string              `json:"playlistId,omitempty"`
+	Title      string              `json:"title"`
+	Artists    []ytRefDocument     `json:"artists,omitempty"`
+	Album      ytRefDocument       `json:"album"`
+	Duration   int                 `json:"duration,omitempty"`
+	IsExplicit bool                `json:"isExplicit,omitempty"`
+	Thumbnails []thumbnailDocument `json:"thumbnails,omitempty"`
+}
+
+type ytRefDocument struct {
+	Name string `json:"name,omitempty"`
+	ID   string `json:"id,omitempty"`
+}
+
+type thumbnailDocument struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// EncodePlaylist writes the playlist to w in the given format.
+func EncodePlaylist(w io.Writer, p *Playlist, format StorageFormat) error {
+	switch format {
+	case GobFormat:
+		return gob.NewEncoder(w).Encode(p)
+	case JSONFormat:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(newPlaylistDocument(p))
+	case JSONGzipFormat:
+		zw := gzip.NewWriter(w)
+		if err := json.NewEncoder(zw).Encode(newPlaylistDocument(p)); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+	return fmt.Errorf("unknown storage format %q", format)
+}
+
+// DecodePlaylist reads a playlist in the given format from r. The JSON
+// documents of the previous schema versions are upgraded.
+func DecodePlaylist(r io.Reader, format StorageFormat) (*Playlist, error) {
+	switch format {
+	case GobFormat:
+		p := &Playlist{}
+		if err := gob.NewDecoder(r).Decode(p); err != nil {
+			return nil, fmt.Errorf("failed to decode the binary file %w", err)
+		}
+		return p, nil
+	case JSONGzipFormat:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress the playlist - %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case JSONFormat:
+	default:
+		return nil, fmt.Errorf("unknown storage format %q", format)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err = upgradePlaylistDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	var doc playlistDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode the playlist document - %w", err)
+	}
+	return doc.playlist(), nil
+}
+
+// upgradePlaylistDocument upgrades a JSON document to StorageSchemaVersion.
+func upgradePlaylistDocument(data []byte) ([]byte, error) {
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to decode the playlist document - %w", err)
+	}
+	switch v := header.SchemaVersion; {
+	case v == StorageSchemaVersion:
+		return data, nil
+	case v < 1:
+		return nil, fmt.Errorf("the playlist document has no schemaVersion")
+	case v > StorageSchemaVersion:
+		return nil, fmt.Errorf("the playlist document has the schema version %d, this version only supports up to %d", v, StorageSchemaVersion)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for v := header.SchemaVersion; v < StorageSchemaVersion; v++ {
+		if err := schemaUpgrades[v-1](doc); err != nil {
+			return nil, fmt.Errorf("failed to upgrade the playlist document from the schema version %d - %w", v, err)
+		}
+		doc["schemaVersion"] = v + 1
+	}
+	return json.Marshal(doc)
+}
+
+func newPlaylistDocument(p *Playlist) *playlistDocument {
+	doc := &playlistDocument{
+		SchemaVersion: StorageSchemaVersion,
+		Name:          p.Name,
+		Year:          p.Year,
+		Month:         p.Month,
+		Day:           p.Day,
+		Yearly:        p.YearlyPlaylist,
+		Tracks:        make([]trackDocument, 0, len(p.Tracks)),
+	}
+	for _, t := range p.Tracks {
+		if t == nil {
+			continue
+		}
+		track := trackDocument{
+			Artist:     t.Artist,
+			Title:      t.Title,
+			Date:       t.Date,
+			Time:       t.Time,
+			Hour:       t.Hour,
+			Minute:     t.Minute,
+			ImgURL:     t.ImgURL,
+			SpotifyURL: t.SpotifyURL,
+			Count:      t.Count,
+			YTArtistID: t.YTArtistID,
+		}
+		if t.YTMusicInfo != nil {
+			track.YTMusic = newYTMatchDocument(t.YTMusicInfo)
+		}
+		doc.Tracks = append(doc.Tracks, track)
+	}
+	return doc
+}
+
+func newYTMatchDocument(info *ytmusic.TrackItem) *ytMatchDocument {
+	match := &ytMatchDocument{
+		VideoID:    info.VideoID,
+		PlaylistID: info.PlaylistID,
+		Title:      info.Title,
+		Album:      ytRefDocument{Name: info.Album.Name, ID: info.Album.ID},
+		Duration:   info.Duration,
+		IsExplicit: info.IsExplicit,
+	}
+	for _, a := range info.Artists {
+		match.Artists = append(match.Artists, ytRefDocument{Name: a.Name, ID: a.ID})
+	}
+	for _, th := range info.Thumbnails {
+		match.Thumbnails = append(match.Thumbnails, thumbnailDocument{URL: th.URL, Width: th.Width, Height: th.Height})
+	}
+	return match
+}
+
+func (doc *playlistDocument) playlist() *Playlist {
+	p := &Playlist{
+		Name:           doc.Name,
+		Year:           doc.Year,
+		Month:          doc.Month,
+		Day:            doc.Day,
+		YearlyPlaylist: doc.Yearly,
+	}
+	for _, t := range doc.Tracks {
+		track := &Track{
+			Artist:     t.Artist,
+			Title:      t.Title,
+			Date:       t.Date,
+			Time:       t.Time,
+			Hour:       t.Hour,
+			Minute:     t.Minute,
+			ImgURL:     t.ImgURL,
+			SpotifyURL: t.SpotifyURL,
+			Count:      t.Count,
+			YTArtistID: t.YTArtistID,
+		}
+		if t.YTMusic != nil {
+			track.YTMusicInfo = t.YTMusic.trackItem()
+		}
+		p.Tracks = append(p.Tracks, track)
+	}
+	return p
+}
+
+func (match *ytMatchDocument) trackItem() *ytmusic.TrackItem {
+	info := &ytmusic.TrackItem{
+		VideoID:    match.VideoID,
+		PlaylistID: match.PlaylistID,
+		Title:      match.Title,
+		Album:      ytmusic.Album{Name: match.Album.Name, ID: match.Album.ID},
+		Duration:   match.Duration,
+		IsExplicit: match.IsExplicit,
+	}
+	for _, a := range match.Artists {
+		info.Artists = append(info.Artists, ytmusic.Artist{Name: a.Name, ID: a.ID})
+	}
+	for _, th := range match.Thumbnails {
+		info.Thumbnails = append(info.Thumbnails, ytmusic.Thumbnail{URL: th.URL, Width: th.Width, Height: th.Height})
+	}
+	return info
+}
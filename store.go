@@ -0,0 +1,337 @@
+package nova
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PlaylistKey identifies a playlist in a Store: the date of the daily, monthly
+// and yearly playlists, the name of the other ones.
+type PlaylistKey struct {
+	Year, Month, Day int
+	// Name is only used by the playlists without a year.
+	Name string
+}
+
+// StoreKey returns the key of the playlist in a Store.
+func (p *Playlist) StoreKey() PlaylistKey {
+	if p.Year > 0 {
+		return PlaylistKey{Year: p.Year, Month: p.Month, Day: p.Day}
+	}
+	return PlaylistKey{Name: p.Name}
+}
+
+// Daily reports if the key is the one of a daily playlist.
+func (k PlaylistKey) Daily() bool {
+	return k.Day > 0
+}
+
+// Monthly reports if the key is the one of a monthly playlist.
+func (k PlaylistKey) Monthly() bool {
+	return k.Month > 0 && k.Day == 0
+}
+
+func (k PlaylistKey) String() string {
+	switch {
+	case k.Day > 0:
+		return fmt.Sprintf("%d-%02d-%02d", k.Year, k.Month, k.Day)
+	case k.Month > 0:
+		return fmt.Sprintf("%d-%02d", k.Year, k.Month)
+	case k.Year > 0:
+		return strconv.Itoa(k.Year)
+	}
+	return k.Name
+}
+
+// Store keeps the playlists.
+type Store interface {
+	// Load loads the playlist with the given key, the error wraps
+	// fs.ErrNotExist when there's none.
+	Load(key PlaylistKey) (*Playlist, error)
+	// Save saves the playlist, replacing the one with the same key.
+	Save(p *Playlist) error
+	// List returns the keys of the playlists selected by filter, all of them
+	// when it's nil, sorted by date.
+	List(filter func(PlaylistKey) bool) ([]PlaylistKey, error)
+	// Delete removes the playlist with the given key.
+	Delete(key PlaylistKey) error
+}
+
+// DailyPlaylists is a filter of Store.List selecting the daily playlists.
+func DailyPlaylists(k PlaylistKey) bool {
+	return k.Daily()
+}
+
+// MonthlyPlaylists is a filter of Store.List selecting the monthly playlists.
+func MonthlyPlaylists(k PlaylistKey) bool {
+	return k.Monthly()
+}
+
+// Playlists is the store of the playlists, in PlaylistDataPath by default.
+var Playlists Store = defaultStore
+
+var defaultStore = &FSStore{}
+
+// StorageFormatMarker is the file recording the storage format of the
+// playlists of a directory, written by nova convert.
+const StorageFormatMarker = ".storage-format"
+
+// FSStore stores the playlists as files in a directory:
+//
+//	2025/03/playlist-2025-03-14.gob  daily playlist
+//	2025/03/playlist-2025-03.gob     monthly playlist
+//	2025/playlist-2025.gob           yearly playlist
+//	playlist-<name>.gob              other playlists
+//
+// The extension is the one of the storage format, the playlists in the
+// other formats are still read.
+type FSStore struct {
+	// Dir is the directory of the playlists, PlaylistDataPath when empty.
+	Dir string
+	// Format is the format of the saved playlists. When empty, it's the one
+	// recorded in the StorageFormatMarker of the directory, gob without one.
+	Format StorageFormat
+
+	markerOnce sync.Once
+	marker     StorageFormat
+}
+
+func (s *FSStore) dir() string {
+	if s.Dir == "" {
+		return PlaylistDataPath
+	}
+	return s.Dir
+}
+
+func (s *FSStore) format() StorageFormat {
+	if s.Format != "" {
+		return s.Format
+	}
+	s.markerOnce.Do(func() {
+		s.marker = GobFormat
+		data, err := os.ReadFile(filepath.Join(s.dir(), StorageFormatMarker))
+		if err != nil {
+			return
+		}
+		if format, err := ParseStorageFormat(strings.TrimSpace(string(data))); err == nil {
+			s.marker = format
+		}
+	})
+	return s.marker
+}
+
+// StorageFormat returns the format the playlists are saved in.
+func (s *FSStore) StorageFormat() StorageFormat {
+	return s.format()
+}
+
+// SetStorageFormat records format in the StorageFormatMarker of the
+// directory so the playlists are saved in it from now on.
+func (s *FSStore) SetStorageFormat(format StorageFormat) error {
+	err := WriteFileAtomic(filepath.Join(s.dir(), StorageFormatMarker), 0644, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, format)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save the storage format - %w", err)
+	}
+	s.Format = format
+	return nil
+}
+
+// Path returns the path of the file of the playlist with the given key.
+func (s *FSStore) Path(key PlaylistKey) string {
+	return s.PathFormat(key, s.format())
+}
+
+// PathFormat returns the path of the file of the playlist with the given
+// key in the given format.
+func (s *FSStore) PathFormat(key PlaylistKey, format StorageFormat) string {
+	switch {
+	case key.Month > 0:
+		return filepath.Join(s.dir(), strconv.Itoa(key.Year), fmt.Sprintf("%02d", key.Month), "playlist-"+key.String()+format.Ext())
+	case key.Year > 0:
+		return filepath.Join(s.dir(), strconv.Itoa(key.Year), "playlist-"+key.String()+format.Ext())
+	}
+	return filepath.Join(s.dir(), "playlist-"+strings.ReplaceAll(key.Name, " ", "")+format.Ext())
+}
+
+// File returns the path of the existing file of the playlist with the given
+// key, in the storage format or else in another one. The error wraps
+// fs.ErrNotExist when there's none.
+func (s *FSStore) File(key PlaylistKey) (string, error) {
+	path := s.Path(key)
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		return path, err
+	}
+	for _, format := range StorageFormats {
+		if format == s.format() {
+			continue
+		}
+		other := s.PathFormat(key, format)
+		if _, err := os.Stat(other); !errors.Is(err, fs.ErrNotExist) {
+			return other, err
+		}
+	}
+	return "", fmt.Errorf("no playlist %s in %s - %w", key, s.dir(), fs.ErrNotExist)
+}
+
+var (
+	storeFilenameRe  = regexp.MustCompile(`^playlist-(\d{4})(?:-(\d{2}))?(?:-(\d{2}))?\.(?:gob|json|json\.gz)$`)
+	legacyFilenameRe = regexp.MustCompile(`^playlist-([A-Za-z]+)-(\d{4})\.gob$`)
+)
+
+// KeyOf returns the key of the playlist stored at path, false if path isn't
+// where the store keeps a playlist.
+func (s *FSStore) KeyOf(path string) (PlaylistKey, bool) {
+	base := filepath.Base(path)
+	var key PlaylistKey
+	format, ok := StorageFormatOf(base)
+	if !ok {
+		return key, false
+	}
+	if m := storeFilenameRe.FindStringSubmatch(base); m != nil {
+		key.Year, _ = strconv.Atoi(m[1])
+		key.Month, _ = strconv.Atoi(m[2])
+		key.Day, _ = strconv.Atoi(m[3])
+	} else if strings.HasPrefix(base, "playlist-") && !IsLegacyFilename(base) {
+		key.Name = strings.TrimSuffix(strings.TrimPrefix(base, "playlist-"), format.Ext())
+	} else {
+		return key, false
+	}
+	return key, filepath.Clean(path) == filepath.Clean(s.PathFormat(key, format))
+}
+
+// IsLegacyFilename reports if filename is the one of a monthly playlist
+// in the previous layout, playlist-<month name>-<year>.gob.
+func IsLegacyFilename(filename string) bool {
+	m := legacyFilenameRe.FindStringSubmatch(filename)
+	if m == nil {
+		return false
+	}
+	for _, month := range English.Months {
+		if m[1] == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *FSStore) Load(key PlaylistKey) (*Playlist, error) {
+	path, err := s.File(key)
+	if err != nil {
+		return nil, err
+	}
+	return LoadPlaylistFromFile(path)
+}
+
+// Save saves the playlist in the storage format and removes its files in
+// the other formats.
+func (s *FSStore) Save(p *Playlist) error {
+	key := p.StoreKey()
+	path := s.Path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to make sure all directories were created - %w", err)
+	}
+	if err := p.SaveToFile(path); err != nil {
+		return err
+	}
+	for _, format := range StorageFormats {
+		if format == s.format() {
+			continue
+		}
+		if err := os.Remove(s.PathFormat(key, format)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the files of the playlist in all the formats.
+func (s *FSStore) Delete(key PlaylistKey) error {
+	removed := false
+	for _, format := range StorageFormats {
+		err := os.Remove(s.PathFormat(key, format))
+		switch {
+		case err == nil:
+			removed = true
+		case !errors.Is(err, fs.ErrNotExist):
+			return err
+		}
+	}
+	if !removed {
+		return fmt.Errorf("no playlist %s in %s - %w", key, s.dir(), fs.ErrNotExist)
+	}
+	return nil
+}
+
+func (s *FSStore) List(filter func(PlaylistKey) bool) ([]PlaylistKey, error) {
+	var keys []PlaylistKey
+	seen := make(map[PlaylistKey]bool)
+	root := s.dir()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// only the year and month directories have playlists
+			if path != root && !yearOrMonthDir(root, path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		key, ok := s.KeyOf(path)
+		// a playlist can be in several formats while it's being converted
+		if ok && !seen[key] && (filter == nil || filter(key)) {
+			keys = append(keys, key)
+		}
+		seen[key] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the playlists in %s - %w", root, err)
+	}
+	sortPlaylistKeys(keys)
+	return keys, nil
+}
+
+var yearDirRe, monthDirRe = regexp.MustCompile(`^\d{4}$`), regexp.MustCompile(`^\d{2}$`)
+
+func yearOrMonthDir(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	switch len(parts) {
+	case 1:
+		return yearDirRe.MatchString(parts[0])
+	case 2:
+		return yearDirRe.MatchString(parts[0]) && monthDirRe.MatchString(parts[1])
+	}
+	return false
+}
+
+// LegacyFiles lists the monthly playlists of the directory in the previous
+// layout, which `nova migrate` moves to the current one.
+func (s *FSStore) LegacyFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.dir())
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && IsLegacyFilename(entry.Name()) {
+			paths = append(paths, filepath.Join(s.dir(), entry.Name()))
+		}
+	}
+	return paths, nil
+}
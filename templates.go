@@ -0,0 +1,220 @@
+package nova
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed templates/*.html
+var embeddedTemplates embed.FS
+
+// DefaultTemplates are the HTML templates shipped with the package:
+// playlist.html for the charts, index.html for the list of charts,
+// track.html for the track pages and artist.html for the artist pages.
+var DefaultTemplates fs.FS = mustSub(embeddedTemplates, "templates")
+
+// Templates is the renderer used by Playlist.ToHTML and the site generator,
+// replace it to theme the site.
+var Templates = mustRenderer(NewRenderer(DefaultTemplates))
+
+// Renderer renders pages from a template set parsed once, in a locale.
+// A Renderer is safe for concurrent use.
+type Renderer struct {
+	// base is never executed so it can be cloned for other locales.
+	base    *template.Template
+	tmpl    *template.Template
+	version string
+	locale  *Locale
+}
+
+// NewRenderer parses the *.html templates of the given layers, each template
+// is named after its file. A file in a layer replaces the file with the same
+// name in the previous layers, so a theme only needs to provide the templates
+// it changes:
+//
+//	nova.NewRenderer(nova.DefaultTemplates, os.DirFS("my-theme"))
+//
+// Since all the templates are in the same set, they can share partials
+// with {{template "file.html" .}}.
+func NewRenderer(layers ...fs.FS) (*Renderer, error) {
+	sources := make(map[string]fs.FS)
+	for _, layer := range layers {
+		names, err := fs.Glob(layer, "*.html")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			sources[name] = layer
+		}
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no templates found")
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	root := template.New("").Funcs(templateFuncs).Funcs(localeFuncs(English, ""))
+	hash := sha256.New()
+	for _, name := range names {
+		data, err := fs.ReadFile(sources[name], name)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", name, len(data))
+		hash.Write(data)
+		if _, err := root.New(path.Base(name)).Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("failed to parse the %s template - %w", name, err)
+		}
+	}
+	r := &Renderer{base: root, version: hex.EncodeToString(hash.Sum(nil))}
+	return r.Localized(English, "")
+}
+
+// Localized returns a renderer of the same templates in the given locale.
+// root is the path from the pages to the root of the site, for the pages
+// generated in a subdirectory (e.g. "../" for the pages in fr/).
+func (r *Renderer) Localized(locale *Locale, root string) (*Renderer, error) {
+	tmpl, err := r.base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{
+		base:    r.base,
+		tmpl:    tmpl.Funcs(localeFuncs(locale, root)),
+		version: r.version,
+		locale:  locale,
+	}, nil
+}
+
+// Locale is the locale the pages are rendered in.
+func (r *Renderer) Locale() *Locale {
+	return r.locale
+}
+
+// Version is a hash of the templates, it changes when any of them does.
+func (r *Renderer) Version() string {
+	return r.version
+}
+
+// Execute renders the named template (e.g. playlist.html) with data to w.
+func (r *Renderer) Execute(w io.Writer, name string, data any) error {
+	t := r.tmpl.Lookup(name)
+	if t == nil {
+		return fmt.Errorf("template %s not found", name)
+	}
+	return t.Execute(w, data)
+}
+
+// Render renders the named template with data.
+func (r *Renderer) Render(name string, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.Execute(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var templateFuncs = template.FuncMap{
+	"addOne": addOne,
+	"unescapeHTML": func(s string) template.HTML {
+		return template.HTML(s)
+	},
+	"minus": func(a, b int) int {
+		return a - b
+	},
+}
+
+// localeFuncs are the template functions depending on the locale and on the
+// location of the pages in the site.
+func localeFuncs(locale *Locale, root string) template.FuncMap {
+	return template.FuncMap{
+		"lang": func() string {
+			return locale.Lang
+		},
+		// root is the path to the root of the site, where the assets are.
+		"root": func() string {
+			return root
+		},
+		// library renders the script tag of one of the Libraries.
+		"library": func(name string) (template.HTML, error) {
+			lib := libraryByName(name)
+			if lib == nil {
+				return "", fmt.Errorf("unknown library %s", name)
+			}
+			return lib.Script(root), nil
+		},
+		"t":      locale.T,
+		"plural": locale.Plural,
+		"title":  locale.PlaylistTitle,
+		"month": func(month int) string {
+			return locale.MonthName(time.Month(month))
+		},
+		// heatmap renders a Heatmap as an inline SVG, nothing when it's nil.
+		// unit is the plural message of its counts, e.g. "plays".
+		"heatmap": func(h *Heatmap, unit string) template.HTML {
+			if h == nil {
+				return ""
+			}
+			return h.SVG(locale, unit)
+		},
+		// hreflang links to the versions of the page at path, relative to
+		// the root of its language, in all the locales.
+		"hreflang": func(path string) template.HTML {
+			prefix := strings.Repeat("../", strings.Count(path, "/")) + root
+			var links strings.Builder
+			for _, l := range Locales {
+				fmt.Fprintf(&links, `<link rel="alternate" hreflang="%s" href="%s">`+"\n",
+					l.Lang, template.HTMLEscapeString(prefix+l.Lang+"/"+path))
+			}
+			fmt.Fprintf(&links, `<link rel="alternate" hreflang="x-default" href="%s">`, template.HTMLEscapeString(prefix+path))
+			return template.HTML(links.String())
+		},
+		"rankingDelta": func(newPosition, oldPosition int) template.HTML {
+			if oldPosition == -1 {
+				return ""
+			}
+			if newPosition < oldPosition {
+				diff := oldPosition - newPosition
+				return template.HTML(fmt.Sprintf(`<div class="ranking-delta up" title="%s">
+  <svg xmlns="http://www.w3.org/2000/svg" height="48" width="48"><path class="arrow-up" d="m24 30-10-9.95h20Z"></path></svg>
+  <span class="ranking-delta-num">%d</span>
+</div>`, template.HTMLEscapeString(locale.T("ranking.up", locale.Plural("places", diff))), diff))
+			} else {
+				diff := newPosition - oldPosition
+				return template.HTML(fmt.Sprintf(`<div class="ranking-delta down" title="%s">
+				<span class="ranking-delta-num">%d</span>
+				<svg xmlns="http://www.w3.org/2000/svg" height="48" width="48"><path class="arrow-down" d="m24 30-10-9.95h20Z"></path></svg>
+			</div>`, template.HTMLEscapeString(locale.T("ranking.down", locale.Plural("places", diff))), diff))
+			}
+		},
+	}
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+func mustRenderer(r *Renderer, err error) *Renderer {
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
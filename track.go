@@ -1,6 +1,8 @@
 package nova
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"time"
@@ -19,24 +21,35 @@ type Track struct {
 	SpotifyURL  string
 	Count       int
 	YTMusicInfo *ytmusic.TrackItem
+	// YTArtistID is the YT Music browse ID of the primary artist when
+	// the track match doesn't include it.
+	YTArtistID string
 }
 
+// YTPrimaryArtistURL returns the YT Music page of the track's primary artist,
+// or "#" if the artist isn't known. It only relies on the track data,
+// use Playlist.PopulateYTArtistIDs to resolve the missing artists beforehand.
 func (t *Track) YTPrimaryArtistURL() string {
-	if t != nil && t.YTMusicInfo != nil {
+	if id := t.YTArtistBrowseID(); id != "" {
+		return fmt.Sprintf("https://music.youtube.com/channel/%s", id)
+	}
+	return "#"
+}
+
+// YTArtistBrowseID returns the YT Music browse ID of the track's primary artist,
+// taken from the track match or resolved by Playlist.PopulateYTArtistIDs.
+func (t *Track) YTArtistBrowseID() string {
+	if t == nil {
+		return ""
+	}
+	if t.YTMusicInfo != nil {
 		for _, artist := range t.YTMusicInfo.Artists {
 			if artist.ID != "" {
-				return fmt.Sprintf("https://music.youtube.com/channel/%s", artist.ID)
+				return artist.ID
 			}
 		}
-		// fmt.Println("YT artist ID missing in the track data for", t.Title, "by", t.Artist, "trying to get it from the search results...")
-		info, err := YTMusic.ArtistInfo(t.Artist)
-		if err == nil && info != nil && info.BrowseID != "" {
-			// fmt.Println("\tFound missing info from search results for", t.Artist, ":", info.Artist)
-			return fmt.Sprintf("https://music.youtube.com/channel/%s", info.BrowseID)
-		}
 	}
-	fmt.Println("Failed to find YT artist info for", t.Artist)
-	return "#"
+	return t.YTArtistID
 }
 
 func (t *Track) YTDuration() string {
@@ -46,10 +59,47 @@ func (t *Track) YTDuration() string {
 	return ""
 }
 
+// DurationSeconds returns the track duration from its YT Music match, 0 if unknown.
+func (t *Track) DurationSeconds() int {
+	if t != nil && t.YTMusicInfo != nil {
+		return t.YTMusicInfo.Duration
+	}
+	return 0
+}
+
+// PlaybackURL returns the best URL to listen to the track:
+// YT Music when matched, Spotify otherwise.
+func (t *Track) PlaybackURL() string {
+	if u := t.YTMusicURL(); u != "" {
+		return u
+	}
+	return t.SpotifyURL
+}
+
 func (t *Track) Key() string {
 	return t.Artist + "|" + t.Title
 }
 
+// ID returns a stable, URL friendly identifier of the track derived from its key.
+// A short hash of the key keeps the IDs unique when different keys share a slug.
+func (t *Track) ID() string {
+	sum := sha1.Sum([]byte(t.Key()))
+	hash := hex.EncodeToString(sum[:3])
+	if slug := Slugify(t.Artist + " " + t.Title); slug != "" {
+		return slug + "-" + hash
+	}
+	return hash
+}
+
+// ArtistSlug returns the URL friendly version of the artist name.
+func (t *Track) ArtistSlug() string {
+	if slug := Slugify(t.Artist); slug != "" {
+		return slug
+	}
+	sum := sha1.Sum([]byte(t.Artist))
+	return hex.EncodeToString(sum[:3])
+}
+
 func (t *Track) YTMusicURL() string {
 	if t.YTMusicInfo != nil {
 		return "https://music.youtube.com/watch?v=" + t.YTMusicInfo.VideoID
@@ -57,9 +107,13 @@ func (t *Track) YTMusicURL() string {
 	return ""
 }
 
+// ytQuery is the YT Music search query used to find the track.
+func (t *Track) ytQuery() string {
+	return fmt.Sprintf("%s by %s", t.Title, t.Artist)
+}
+
 func (track *Track) GetYTMusicInfo() *ytmusic.TrackItem {
-	query := fmt.Sprintf("%s by %s", track.Title, track.Artist)
-	info, err := YTMusic.TrackInfo(query)
+	info, err := YTMusic.TrackInfo(track.ytQuery())
 	if err != nil {
 		log.Println(err)
 		return nil
@@ -22,12 +22,56 @@ async function initializePlayer() {
     return match ? match[1] : '';
   }
 
+  // Load the playlist from the static JSON API the page links to (body[data-api]),
+  // falling back to reading the page if the API isn't available.
+  async function loadQueue() {
+    const api = document.body.dataset.api;
+    if (api) {
+      try {
+        const res = await fetch(api);
+        if (!res.ok) throw new Error(`HTTP ${res.status}`);
+        const playlist = await res.json();
+        return playlist.tracks
+          .filter(t => t.youtubeMusic && t.youtubeMusic.videoId)
+          .map(t => ({
+            id: t.id,
+            title: t.title,
+            artist: t.artist,
+            videoId: t.youtubeMusic.videoId
+          }));
+      } catch (err) {
+        console.error('Failed to load the playlist from the API, reading the page instead:', err);
+      }
+    }
+    return queueFromDOM();
+  }
+
+  function queueFromDOM() {
+    return Array.from(document.querySelectorAll('.playlist-entry'))
+      .map(el => {
+        const link = el.querySelector('a[href*="music.youtube.com"]');
+        if (!link) return null;
+        return {
+          id: el.dataset.trackId || el.dataset.title || link.href,
+          title: el.querySelector('.title')?.textContent || 'Unknown Title',
+          artist: el.querySelector('.artist-name')?.textContent || 'Unknown Artist',
+          videoId: extractVideoId(link.href)
+        };
+      })
+      .filter(Boolean);
+  }
+
   function NovaPlayer() {
     const youtubePlayerARef = useRef(null);
     const youtubePlayerBRef = useRef(null);
 
-    // Playlist queue loaded from the DOM (for preloading)
+    // Playlist queue loaded from the JSON API (for preloading)
     const [queue, setQueue] = useState([]);
+    // Latest queue, for the row click listeners attached on mount
+    const queueRef = useRef([]);
+    useEffect(() => {
+      queueRef.current = queue;
+    }, [queue]);
     const [currentIndex, setCurrentIndex] = useState(0);
     // Which deck is active: 'A' or 'B'
     const [activeDeck, setActiveDeck] = useState('A');
@@ -54,31 +98,24 @@ async function initializePlayer() {
     const [playerAReady, setPlayerAReady] = useState(false);
     const [playerBReady, setPlayerBReady] = useState(false);
 
-    // 1) Load tracks from .playlist-entry and attach click listeners
+    // 1) Load tracks from the JSON API and attach click listeners to .playlist-entry
     useEffect(() => {
-      const entries = document.querySelectorAll('.playlist-entry');
-      const loaded = Array.from(entries)
-        .map(el => {
-          const link = el.querySelector('a[href*="music.youtube.com"]');
-          if (!link) return null;
-          return {
-            id: el.dataset.title || link.href,
-            title: el.querySelector('.title')?.textContent || 'Unknown Title',
-            artist: el.querySelector('.artist-name')?.textContent || 'Unknown Artist',
-            videoId: extractVideoId(link.href)
-          };
-        })
-        .filter(Boolean);
-
-      setQueue(loaded);
-      // Preload first two tracks into deck A and deck B
-      if (loaded[0]) setTrackA(loaded[0]);
-      if (loaded[1]) setTrackB(loaded[1]);
+      let cancelled = false;
+      loadQueue().then(loaded => {
+        if (cancelled) return;
+        queueRef.current = loaded;
+        setQueue(loaded);
+        // Preload first two tracks into deck A and deck B
+        if (loaded[0]) setTrackA(loaded[0]);
+        if (loaded[1]) setTrackB(loaded[1]);
+      });
 
+      const entries = document.querySelectorAll('.playlist-entry');
       entries.forEach(row => {
         row.addEventListener('click', handleRowClick);
       });
       return () => {
+        cancelled = true;
         entries.forEach(row => {
           row.removeEventListener('click', handleRowClick);
         });
@@ -318,6 +355,14 @@ async function initializePlayer() {
       e.preventDefault();
 
       const row = e.currentTarget;
+      const queued = queueRef.current;
+      const queuedIndex = queued.findIndex(t => t.id === row.dataset.trackId);
+      if (queuedIndex >= 0) {
+        playClickedTrack(queued[queuedIndex], queued[queuedIndex + 1]);
+        return;
+      }
+
+      // The track isn't in the queue, read it from the row
       const titleElem = row.querySelector('.title');
       const artistElem = row.querySelector('.artist-name');
       const link = row.querySelector('a[href*="music.youtube.com"]');
@@ -329,22 +374,28 @@ async function initializePlayer() {
       if (!videoId) return;
       const clickedTrack = { title, artist, videoId };
 
-      // Load the clicked track into the active deck
-      if (activeDeckRef.current === 'A') {
-        setTrackA(clickedTrack);
-      } else {
-        setTrackB(clickedTrack);
-      }
-
-      // Preload next track into the inactive deck if available
+      let nextTrack = null;
       const nextRow = row.nextElementSibling;
       if (nextRow) {
         const nextTitle = nextRow.querySelector('.title')?.textContent || 'Unknown Title';
         const nextArtist = nextRow.querySelector('.artist-name')?.textContent || 'Unknown Artist';
         const nextLink = nextRow.querySelector('a[href*="music.youtube.com"]');
         const nextVideoId = nextLink ? extractVideoId(nextLink.href) : '';
-        const nextTrack = { title: nextTitle, artist: nextArtist, videoId: nextVideoId };
+        nextTrack = { title: nextTitle, artist: nextArtist, videoId: nextVideoId };
+      }
+      playClickedTrack(clickedTrack, nextTrack);
+    }
+
+    // Load the clicked track into the active deck and preload the next one
+    // into the inactive deck if available.
+    function playClickedTrack(clickedTrack, nextTrack) {
+      if (activeDeckRef.current === 'A') {
+        setTrackA(clickedTrack);
+      } else {
+        setTrackB(clickedTrack);
+      }
 
+      if (nextTrack) {
         if (activeDeckRef.current === 'A') {
           setTrackB(nextTrack);
         } else {
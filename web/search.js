@@ -0,0 +1,95 @@
+// Offline search over web/search-index.json, generated by bin/main.go.
+// Each track in the index is [artist, title, id, [chart indexes], total count].
+document.addEventListener("DOMContentLoaded", function() {
+  const input = document.querySelector("#search-input");
+  const status = document.querySelector("#search-status");
+  const results = document.querySelector("#search-results");
+  const maxResults = 50;
+  const maxChartLinks = 6;
+  let index = null;
+
+  // lowercase and strip the accents so "cafe" finds "café"
+  function normalize(s) {
+    return s.normalize("NFD").replace(/[\u0300-\u036f]/g, "").toLowerCase();
+  }
+
+  function chartTitle(name) {
+    return name.replace("-", " ");
+  }
+
+  function search(query) {
+    const terms = normalize(query).split(/\s+/).filter(Boolean);
+    if (terms.length === 0) return [];
+    const matches = [];
+    for (const track of index.tracks) {
+      if (terms.every(term => track.haystack.includes(term))) {
+        matches.push(track);
+        if (matches.length >= maxResults) break;
+      }
+    }
+    return matches;
+  }
+
+  function render(query) {
+    results.innerHTML = "";
+    if (!query.trim()) {
+      status.textContent = `${index.tracks.length} tracks in ${index.charts.length} monthly charts`;
+      return;
+    }
+    const matches = search(query);
+    status.textContent = matches.length === maxResults ? `Top ${maxResults} results` : `${matches.length} results`;
+
+    for (const [artist, title, id, charts, count] of matches) {
+      const li = document.createElement("li");
+      li.className = "search-result";
+
+      const link = document.createElement("a");
+      link.href = `tracks/${id}.html`;
+      link.className = "track";
+      link.textContent = `${title} by ${artist}`;
+      li.appendChild(link);
+
+      const details = document.createElement("span");
+      details.className = "details";
+      details.textContent = ` ${count} plays in ${charts.length} ${charts.length > 1 ? "months" : "month"}: `;
+      li.appendChild(details);
+
+      // most recent charts first
+      charts.slice(-maxChartLinks).reverse().forEach((chart, i) => {
+        const name = index.charts[chart];
+        const chartLink = document.createElement("a");
+        chartLink.href = `${name}.html`;
+        chartLink.className = "chart";
+        chartLink.textContent = chartTitle(name);
+        if (i > 0) li.appendChild(document.createTextNode(", "));
+        li.appendChild(chartLink);
+      });
+      if (charts.length > maxChartLinks) li.appendChild(document.createTextNode("…"));
+
+      results.appendChild(li);
+    }
+  }
+
+  status.textContent = "Loading the index…";
+  fetch("search-index.json")
+    .then(res => {
+      if (!res.ok) throw new Error(`HTTP ${res.status}`);
+      return res.json();
+    })
+    .then(data => {
+      index = data;
+      index.tracks.forEach(track => {
+        track.haystack = normalize(`${track[0]} ${track[1]}`);
+      });
+      const params = new URLSearchParams(window.location.search);
+      if (params.get("q")) input.value = params.get("q");
+      render(input.value);
+      input.addEventListener("input", () => render(input.value));
+    })
+    .catch(err => {
+      status.textContent = "Failed to load the search index.";
+      console.error(err);
+    });
+
+  document.querySelector("#search-form").addEventListener("submit", e => e.preventDefault());
+});
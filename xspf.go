@@ -0,0 +1,75 @@
+package nova
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+// xspfPlaylist is the root element of an XSPF document, see https://xspf.org/spec
+type xspfPlaylist struct {
+	XMLName   xml.Name    `xml:"playlist"`
+	Version   string      `xml:"version,attr"`
+	Namespace string      `xml:"xmlns,attr"`
+	Title     string      `xml:"title,omitempty"`
+	Creator   string      `xml:"creator,omitempty"`
+	Info      string      `xml:"info,omitempty"`
+	Tracks    []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Locations  []string `xml:"location"`
+	Title      string   `xml:"title,omitempty"`
+	Creator    string   `xml:"creator,omitempty"`
+	Annotation string   `xml:"annotation,omitempty"`
+	Info       string   `xml:"info,omitempty"`
+	Image      string   `xml:"image,omitempty"`
+	TrackNum   int      `xml:"trackNum,omitempty"`
+	// Duration is in milliseconds
+	Duration int `xml:"duration,omitempty"`
+}
+
+// ToXSPF returns the playlist in the XSPF format.
+// Each track lists its YT Music and Spotify URLs as locations.
+func (p *Playlist) ToXSPF() ([]byte, error) {
+	doc := xspfPlaylist{
+		Version:   "1",
+		Namespace: "http://xspf.org/ns/0/",
+		Title:     "Radio Nova " + p.Title(),
+		Creator:   "Radio Nova",
+		Info:      "https://www.nova.fr/c-etait-quoi-ce-titre/",
+	}
+
+	for i, track := range p.Tracks {
+		entry := xspfTrack{
+			Title:    track.Title,
+			Creator:  track.Artist,
+			Info:     track.YTPrimaryArtistURL(),
+			Image:    track.ThumbURL(),
+			TrackNum: i + 1,
+			Duration: track.DurationSeconds() * 1000,
+		}
+		if entry.Info == "#" {
+			entry.Info = ""
+		}
+		if track.Count > 0 {
+			entry.Annotation = fmt.Sprintf("%d plays", track.Count)
+		}
+		for _, location := range []string{track.YTMusicURL(), track.SpotifyURL} {
+			if location != "" {
+				entry.Locations = append(entry.Locations, location)
+			}
+		}
+		doc.Tracks = append(doc.Tracks, entry)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode the XSPF playlist %w", err)
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
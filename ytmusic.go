@@ -3,9 +3,14 @@ package nova
 import (
 	"compress/gzip"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/raitonoberu/ytmusic"
 )
@@ -13,52 +18,246 @@ import (
 var (
 	YTMusicCachePath = "data/ytmusic.gob.gz"
 	YTMusic          *YTMusicCache
+	// YTSearchInterval is the minimum delay between two YT Music searches.
+	// The limit is shared by all the goroutines using the cache.
+	YTSearchInterval = 250 * time.Millisecond
+	// YTMusicCacheBackups is the number of previous versions of the cache kept
+	// next to it (ytmusic.gob.gz.1 being the most recent).
+	YTMusicCacheBackups = 3
+	// YTMusicCheckpointInterval is how often PopulateYTIDs saves the cache
+	// while it's running. Set it to 0 to disable checkpoints.
+	YTMusicCheckpointInterval = 2 * time.Minute
+	// YTMusicMissRetry is how long an artist which couldn't be found is
+	// remembered before being searched again.
+	YTMusicMissRetry = 30 * 24 * time.Hour
 )
 
+// errNoYTArtist is returned when YT Music has no artist for a query, as
+// opposed to a failed search.
+var errNoYTArtist = errors.New("no artist info found")
+
+// ytLimiter throttles the searches sent to YT Music.
+var ytLimiter rateLimiter
+
+// YTMusicCache stores the YT Music search results by query.
+// It is safe for concurrent use.
 type YTMusicCache struct {
 	Matches map[string]*ytmusic.SearchResult
+	// MatchedAt records when each match was retrieved.
+	// Entries cached by older versions don't have a timestamp.
+	MatchedAt map[string]time.Time
+
+	mu     sync.RWMutex
+	saveMu sync.Mutex
+	// checkpointed is set when a checkpoint already rotated the backups
+	// since the last Save.
+	checkpointed bool
+	// inflight tracks the searches currently running so concurrent
+	// lookups for the same query share a single request.
+	inflight map[string]*ytSearchCall
+}
+
+type ytSearchCall struct {
+	done   chan struct{}
+	result *ytmusic.SearchResult
+	err    error
+}
+
+// rateLimiter spaces out calls so they happen at most once per interval.
+type rateLimiter struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the caller is allowed to make its call.
+func (l *rateLimiter) wait(interval time.Duration) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(interval)
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// match returns the cached search result for the query, if any.
+func (yt *YTMusicCache) match(query string) *ytmusic.SearchResult {
+	yt.mu.RLock()
+	defer yt.mu.RUnlock()
+	return yt.Matches[query]
+}
+
+func (yt *YTMusicCache) store(query string, result *ytmusic.SearchResult) {
+	yt.mu.Lock()
+	defer yt.mu.Unlock()
+	if yt.Matches == nil {
+		yt.Matches = make(map[string]*ytmusic.SearchResult)
+	}
+	if yt.MatchedAt == nil {
+		yt.MatchedAt = make(map[string]time.Time)
+	}
+	yt.Matches[query] = result
+	yt.MatchedAt[query] = time.Now()
+}
+
+// Queries returns the cached queries sorted alphabetically.
+func (yt *YTMusicCache) Queries() []string {
+	yt.mu.RLock()
+	defer yt.mu.RUnlock()
+	queries := make([]string, 0, len(yt.Matches))
+	for query := range yt.Matches {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	return queries
+}
+
+// Lookup returns the cached result for the query and when it was retrieved.
+// The time is zero if the entry predates the timestamps.
+func (yt *YTMusicCache) Lookup(query string) (*ytmusic.SearchResult, time.Time, bool) {
+	yt.mu.RLock()
+	defer yt.mu.RUnlock()
+	result, ok := yt.Matches[query]
+	return result, yt.MatchedAt[query], ok
+}
+
+// Revision identifies the content of the cache, it changes when entries are
+// added, revalidated or evicted.
+func (yt *YTMusicCache) Revision() string {
+	yt.mu.RLock()
+	defer yt.mu.RUnlock()
+	var latest time.Time
+	for _, matchedAt := range yt.MatchedAt {
+		if matchedAt.After(latest) {
+			latest = matchedAt
+		}
+	}
+	return fmt.Sprintf("%d-%d", len(yt.Matches), latest.UnixNano())
+}
+
+// Evict removes the cached result for the query and reports if there was one.
+func (yt *YTMusicCache) Evict(query string) bool {
+	yt.mu.Lock()
+	defer yt.mu.Unlock()
+	_, ok := yt.Matches[query]
+	delete(yt.Matches, query)
+	delete(yt.MatchedAt, query)
+	return ok
+}
+
+// Revalidate runs the search for the query again and replaces the cached result.
+// The cached result is kept if the new search doesn't return anything.
+func (yt *YTMusicCache) Revalidate(query string) error {
+	if yt == nil {
+		return fmt.Errorf("YT Music cache is not loaded, load it first using nova.LoadYTMusicCache()")
+	}
+	result, _, err := yt.search(query)
+	if err != nil {
+		return err
+	}
+	if result == nil || (len(result.Tracks) == 0 && len(result.Artists) == 0) {
+		return fmt.Errorf("no results for %s", query)
+	}
+	yt.store(query, result)
+	return nil
+}
+
+// search runs a YT Music search for the query, sharing the request with
+// any other goroutine already searching for the same query.
+// The result isn't cached, callers decide if it's worth storing.
+// The returned boolean is true when no request was sent on behalf of the caller.
+func (yt *YTMusicCache) search(query string) (*ytmusic.SearchResult, bool, error) {
+	yt.mu.Lock()
+	if call, ok := yt.inflight[query]; ok {
+		yt.mu.Unlock()
+		<-call.done
+		return call.result, true, call.err
+	}
+	if yt.inflight == nil {
+		yt.inflight = make(map[string]*ytSearchCall)
+	}
+	call := &ytSearchCall{done: make(chan struct{})}
+	yt.inflight[query] = call
+	yt.mu.Unlock()
+
+	ytLimiter.wait(YTSearchInterval)
+	call.result, call.err = ytmusic.Search(query).Next()
+	if call.err != nil {
+		call.err = fmt.Errorf("failed to get the next yt music result for %s: %w", query, call.err)
+	}
+
+	yt.mu.Lock()
+	delete(yt.inflight, query)
+	yt.mu.Unlock()
+	close(call.done)
+
+	return call.result, false, call.err
 }
 
 func (yt *YTMusicCache) TrackInfo(query string) (*ytmusic.TrackItem, error) {
+	info, _, err := yt.trackInfo(query)
+	return info, err
+}
+
+// trackInfo is like TrackInfo but also reports if the info was resolved
+// without sending a new search.
+func (yt *YTMusicCache) trackInfo(query string) (*ytmusic.TrackItem, bool, error) {
 	if yt == nil {
-		return nil, fmt.Errorf("YT Music cache is not loaded, load it first using nova.LoadYTMusicCache()")
+		return nil, false, fmt.Errorf("YT Music cache is not loaded, load it first using nova.LoadYTMusicCache()")
 	}
-	if yt.Matches[query] != nil {
-		return yt.Matches[query].Tracks[0], nil
+	if m := yt.match(query); m != nil && len(m.Tracks) > 0 {
+		return m.Tracks[0], true, nil
 	}
 
-	s := ytmusic.Search(query)
-	fmt.Printf("ytmusic search for %s\n", query)
-	result, err := s.Next()
+	result, shared, err := yt.search(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get the next yt music result for %s: %w", query, err)
+		return nil, shared, err
 	}
-	if (len(result.Tracks)) == 0 {
-		return nil, fmt.Errorf("no results for %s", query)
+	if result == nil || len(result.Tracks) == 0 {
+		return nil, shared, fmt.Errorf("no results for %s", query)
 	}
-	yt.Matches[query] = result
+	yt.store(query, result)
 
 	// TODO: double check that the top result is a match
 
-	// fmt.Printf("Got YTMusicID for %s by %s : %+v/n", track.Title, track.Artist, result.Tracks[0])
-	return result.Tracks[0], nil
+	return result.Tracks[0], shared, nil
 }
 
+// ArtistInfo returns the YT Music artist of the query. The queries without
+// an artist are cached as an empty result and only searched again after
+// YTMusicMissRetry.
 func (yt *YTMusicCache) ArtistInfo(query string) (*ytmusic.ArtistItem, error) {
 	if yt == nil {
 		return nil, fmt.Errorf("YT Music cache is not loaded, load it first using nova.LoadYTMusicCache()")
 	}
-	// if query contains "and", split it and search for each artist
 
-	if m := yt.Matches[query]; m != nil {
-		for _, a := range m.Artists {
-			if a != nil && a.BrowseID != "" {
-				return a, nil
+	if m, matchedAt, ok := yt.Lookup(query); ok {
+		if m != nil {
+			for _, a := range m.Artists {
+				if a != nil && a.BrowseID != "" {
+					return a, nil
+				}
 			}
 		}
-		return nil, fmt.Errorf("no artist info found for %s", query)
+		if time.Since(matchedAt) < YTMusicMissRetry {
+			return nil, fmt.Errorf("%w for %s", errNoYTArtist, query)
+		}
+	}
+
+	artist, err := yt.searchArtist(query)
+	if errors.Is(err, errNoYTArtist) {
+		yt.miss(query)
 	}
+	return artist, err
+}
 
+// searchArtist searches the artist of the query. The results with an artist
+// are cached.
+func (yt *YTMusicCache) searchArtist(query string) (*ytmusic.ArtistItem, error) {
+	// if query contains "and", split it and search for each artist
 	lcQ := strings.ToLower(query)
 	if strings.Contains(lcQ, "and") {
 		return yt.artistInfoForList(strings.Split(lcQ, "and")...)
@@ -67,17 +266,15 @@ func (yt *YTMusicCache) ArtistInfo(query string) (*ytmusic.ArtistItem, error) {
 		return yt.artistInfoForList(strings.Split(lcQ, "&")...)
 	}
 
-	s := ytmusic.Search(query)
-	fmt.Printf("ytmusic search for %s\n", query)
-	result, err := s.Next()
+	result, _, err := yt.search(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get the next yt music result for %s: %w", query, err)
+		return nil, err
 	}
 
 	if result == nil || len(result.Artists) == 0 {
-		return nil, fmt.Errorf("no artist results for %s", query)
+		return nil, fmt.Errorf("%w for %s", errNoYTArtist, query)
 	}
-	yt.Matches[query] = result
+	yt.store(query, result)
 
 	for _, a := range result.Artists {
 		if a.BrowseID != "" {
@@ -85,18 +282,33 @@ func (yt *YTMusicCache) ArtistInfo(query string) (*ytmusic.ArtistItem, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("no artist info found for %s", query)
+	return nil, fmt.Errorf("%w for %s", errNoYTArtist, query)
+}
+
+// miss caches an empty result for a query YT Music has no artist for, unless
+// it already has a result.
+func (yt *YTMusicCache) miss(query string) {
+	if m := yt.match(query); m != nil && len(m.Artists) > 0 {
+		return
+	}
+	yt.store(query, &ytmusic.SearchResult{})
 }
 
+// artistInfoForList returns the first artist of names found on YT Music.
+// errNoYTArtist is only returned when none of them could be searched.
 func (yt *YTMusicCache) artistInfoForList(names ...string) (*ytmusic.ArtistItem, error) {
 	if yt == nil {
 		return nil, fmt.Errorf("YT Music cache is not loaded, load it first using nova.LoadYTMusicCache()")
 	}
+	var searchErr error
 	for _, artist := range names {
 		artist = strings.TrimSpace(artist)
 		artistInfo, err := yt.ArtistInfo(artist)
 		if err != nil {
 			fmt.Printf("failed to get artist info for %s: %v\n", artist, err)
+			if !errors.Is(err, errNoYTArtist) {
+				searchErr = err
+			}
 			continue
 		}
 		if artistInfo != nil && artistInfo.BrowseID != "" {
@@ -104,72 +316,151 @@ func (yt *YTMusicCache) artistInfoForList(names ...string) (*ytmusic.ArtistItem,
 		}
 		fmt.Println("No artist info found for ", artist)
 	}
-	return nil, fmt.Errorf("no artist info found")
+	if searchErr != nil {
+		return nil, searchErr
+	}
+	return nil, errNoYTArtist
 }
 
 func (yt *YTMusicCache) Save() error {
 	if yt == nil {
 		return fmt.Errorf("yt music cache is not loaded")
 	}
-	file, err := os.Create(YTMusicCachePath)
-	if err != nil {
-		return fmt.Errorf("failed to create the yt cache file %w", err)
+	yt.saveMu.Lock()
+	defer yt.saveMu.Unlock()
+
+	if !yt.checkpointed {
+		if err := rotateYTMusicCacheBackups(); err != nil {
+			fmt.Println("Error rotating the YT music cache backups:", err)
+		}
 	}
-	defer file.Close()
+	yt.checkpointed = false
+	return yt.write()
+}
 
-	gzipWriter := gzip.NewWriter(file)
-	defer gzipWriter.Close()
+// Checkpoint writes the cache while it's being filled. Only the first
+// checkpoint before a Save rotates the backups, so the ones made before the
+// run aren't pushed out by the checkpoints of a long run.
+func (yt *YTMusicCache) Checkpoint() error {
+	if yt == nil {
+		return fmt.Errorf("yt music cache is not loaded")
+	}
+	yt.saveMu.Lock()
+	defer yt.saveMu.Unlock()
 
-	encoder := gob.NewEncoder(gzipWriter)
-	if err := encoder.Encode(yt); err != nil {
-		return fmt.Errorf("failed to encode the ytmusic cache %w", err)
+	if !yt.checkpointed {
+		if err := rotateYTMusicCacheBackups(); err != nil {
+			fmt.Println("Error rotating the YT music cache backups:", err)
+		}
+		yt.checkpointed = true
 	}
-	return nil
+	return yt.write()
 }
 
-func LoadYTMusicCache() (*YTMusicCache, error) {
-	// check if the YTMusicCachePath exists
-	// if it does, it opens the file
-	_, err := os.Stat(YTMusicCachePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Println("Creating a new cache")
-			file, err := os.Create(YTMusicCachePath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create the cache file %w", err)
-			}
-			defer file.Close()
-			YTMusic = &YTMusicCache{Matches: make(map[string]*ytmusic.SearchResult)}
-			return YTMusic, nil
+func (yt *YTMusicCache) write() error {
+	return WriteFileAtomic(YTMusicCachePath, 0644, func(w io.Writer) error {
+		gzipWriter := gzip.NewWriter(w)
+
+		yt.mu.RLock()
+		err := gob.NewEncoder(gzipWriter).Encode(yt)
+		yt.mu.RUnlock()
+		if err != nil {
+			return fmt.Errorf("failed to encode the ytmusic cache %w", err)
+		}
+		return gzipWriter.Close()
+	})
+}
+
+// ytMusicCacheBackupPath returns the path of the nth backup, 1 being the most recent.
+func ytMusicCacheBackupPath(n int) string {
+	return fmt.Sprintf("%s.%d", YTMusicCachePath, n)
+}
+
+// rotateYTMusicCacheBackups shifts the existing backups and links the current
+// cache file as the most recent backup. The current file stays in place
+// so a crash during the rotation never leaves us without a cache.
+func rotateYTMusicCacheBackups() error {
+	if YTMusicCacheBackups < 1 || !FileExists(YTMusicCachePath) {
+		return nil
+	}
+	for n := YTMusicCacheBackups - 1; n >= 1; n-- {
+		if !FileExists(ytMusicCacheBackupPath(n)) {
+			continue
+		}
+		if err := os.Rename(ytMusicCacheBackupPath(n), ytMusicCacheBackupPath(n+1)); err != nil {
+			return err
 		}
 	}
+	latest := ytMusicCacheBackupPath(1)
+	os.Remove(latest)
+	if err := os.Link(YTMusicCachePath, latest); err == nil {
+		return nil
+	}
+	// hard links aren't available everywhere, fall back to a copy.
+	data, err := os.ReadFile(YTMusicCachePath)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(latest, 0644, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
 
-	file, err := os.Open(YTMusicCachePath)
+// readYTMusicCache decodes the cache stored at path.
+func readYTMusicCache(path string) (*YTMusicCache, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		fmt.Printf("failed to open the file from disk %v\n", err)
-		fmt.Println("Creating a new cache")
-		file, err = os.Create(YTMusicCachePath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create the cache file %w", err)
-		}
-		YTMusic = &YTMusicCache{Matches: make(map[string]*ytmusic.SearchResult)}
-		return YTMusic, nil
+		return nil, err
 	}
 	defer file.Close()
 
-	YTMusic = &YTMusicCache{Matches: make(map[string]*ytmusic.SearchResult)}
-
-	// decode the file into playlist
 	gzipReader, err := gzip.NewReader(file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
 	}
 	defer gzipReader.Close()
 
-	// decode the file into playlist
-	decoder := gob.NewDecoder(gzipReader)
-	if err := decoder.Decode(YTMusic); err != nil {
+	cache := &YTMusicCache{Matches: make(map[string]*ytmusic.SearchResult)}
+	if err := gob.NewDecoder(gzipReader).Decode(cache); err != nil {
 		return nil, fmt.Errorf("failed to decode the yt music cache %w", err)
 	}
+	return cache, nil
+}
+
+// LoadYTMusicCache loads the cache from YTMusicCachePath. If the file is empty
+// or corrupted, the most recent backup that can be decoded is used instead.
+// A new, empty cache is used when no cache file exists yet.
+func LoadYTMusicCache() (*YTMusicCache, error) {
+	paths := []string{YTMusicCachePath}
+	for n := 1; n <= YTMusicCacheBackups; n++ {
+		paths = append(paths, ytMusicCacheBackupPath(n))
+	}
+
+	var lastErr error
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		// empty files are left behind by older versions, skip them like missing files.
+		if err != nil || info.Size() == 0 {
+			continue
+		}
+		cache, err := readYTMusicCache(path)
+		if err != nil {
+			fmt.Printf("failed to load the YT music cache from %s: %v\n", path, err)
+			lastErr = err
+			continue
+		}
+		if i > 0 {
+			fmt.Println("Recovered the YT music cache from backup", path)
+		}
+		YTMusic = cache
+		return YTMusic, nil
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("no usable YT music cache or backup found: %w", lastErr)
+	}
+
+	fmt.Println("Creating a new cache")
+	YTMusic = &YTMusicCache{Matches: make(map[string]*ytmusic.SearchResult)}
 	return YTMusic, nil
 }